@@ -0,0 +1,214 @@
+// Package expansion loads blueprint files and expands them into datacenters and replications.
+package expansion
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+	"github.com/dbenque/couchbaseblueprint/rules"
+)
+
+// Blueprint is the expanded set of datacenters together with the XDCR definitions applied to them.
+type Blueprint struct {
+	Datacenters []model.Datacenter
+	XDCRSets    []XDCRSet
+	// Files are the files the blueprint is read from
+	Files []string
+}
+
+// XDCRSet is a list of XDCR definitions and the datacenters they are evaluated against.
+type XDCRSet struct {
+	File        string
+	Definitions []model.XDCRDef
+	Datacenters []model.Datacenter
+	// replications are the ones of the definitions once the set is expanded, nil before
+	replications [][]model.XDCR
+}
+
+// Replications returns the replications of each definition of the set, in the order of the definitions. The
+// definitions are evaluated in parallel over a single index of the buckets, unless the set is already expanded.
+func (set XDCRSet) Replications() [][]model.XDCR {
+	if set.replications != nil {
+		return set.replications
+	}
+	return rules.NewXDCRs(set.Definitions, set.Datacenters)
+}
+
+// Expand returns a copy of the blueprint whose sets hold their replications, so that the checks run on it do not
+// evaluate the definitions again. The replications of the copy are shared and must not be modified.
+func (bp *Blueprint) Expand() *Blueprint {
+	expanded := *bp
+	expanded.XDCRSets = make([]XDCRSet, len(bp.XDCRSets))
+	for i, set := range bp.XDCRSets {
+		set.replications = set.Replications()
+		expanded.XDCRSets[i] = set
+	}
+	return &expanded
+}
+
+// XDCRs returns the replications produced by all the definitions of the blueprint.
+func (bp *Blueprint) XDCRs() []model.XDCR {
+	result := []model.XDCR{}
+	bp.EachXDCR(func(x model.XDCR) error {
+		result = append(result, x)
+		return nil
+	})
+	return result
+}
+
+// EachXDCR calls emit with each replication of the blueprint, in the order of XDCRs, and stops at the first error
+// it returns. Only the replications of one set are held at once.
+func (bp *Blueprint) EachXDCR(emit func(model.XDCR) error) error {
+	for _, set := range bp.XDCRSets {
+		for _, xdcrs := range set.Replications() {
+			for _, x := range xdcrs {
+				if err := emit(x); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// BlueprintFromFolder expands the couchbase and XDCR files of the folder in each of the datacenters. The optional
+// datacenters file of the folder gives the labels and the overrides of the datacenters by name. The files are looked
+// up with the extensions of format, or of any format when it is empty.
+func BlueprintFromFolder(folder, format string, DCs []model.Datacenter) (*Blueprint, error) {
+	files := []string{}
+	if dcFile, err := FindFile(folder, "datacenters", format); err == nil {
+		files = append(files, dcFile)
+		dcdefs, err := DatacenterDefsFromFile(dcFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyDatacenterDefs(dcFile, dcdefs, DCs); err != nil {
+			return nil, err
+		}
+	}
+
+	topoFile, err := FindFile(folder, "couchbase", format)
+	if err != nil {
+		return nil, err
+	}
+	xdcrFile, err := FindFile(folder, "XDCR", format)
+	if err != nil {
+		return nil, err
+	}
+	bp, err := BlueprintFromFiles(topoFile, xdcrFile, DCs)
+	if err != nil {
+		return nil, err
+	}
+	bp.Files = append(files, bp.Files...)
+	return bp, nil
+}
+
+// BlueprintFromFiles expands the topology file in each of the datacenters and evaluates the definitions of the
+// optional XDCR file against them.
+func BlueprintFromFiles(topoFile, xdcrFile string, DCs []model.Datacenter) (*Blueprint, error) {
+	DCs, err := TopoFromFile(topoFile, DCs)
+	if err != nil {
+		return nil, err
+	}
+	bp := &Blueprint{Datacenters: DCs, XDCRSets: []XDCRSet{}, Files: []string{topoFile}}
+	if xdcrFile == "" {
+		return bp, nil
+	}
+	bp.Files = append(bp.Files, xdcrFile)
+	defs, err := XDCRDefsFromFile(xdcrFile)
+	if err != nil {
+		return nil, err
+	}
+	bp.XDCRSets = append(bp.XDCRSets, XDCRSet{File: xdcrFile, Definitions: defs, Datacenters: DCs})
+	return bp, nil
+}
+
+func BlueprintFromDCFile(file string) (*Blueprint, error) {
+	var dcinjector DCInjector
+	if err := unmarshalFile(file, &dcinjector); err != nil {
+		return nil, err
+	}
+
+	dcdefs := map[string]model.DatacenterDef{}
+	for _, def := range dcinjector.Datacenters {
+		dcdefs[def.Name] = def
+	}
+
+	files := []string{file}
+	datacenters := map[string]model.Datacenter{}
+	for _, f := range sortedKeys(dcinjector.Topos) {
+		files = append(files, f)
+		for _, d := range dcinjector.Topos[f] {
+			aDc, ok := datacenters[d]
+			if !ok {
+				def, ok := dcdefs[d]
+				if !ok {
+					def = model.DatacenterDef{Name: d}
+				}
+				aDc = model.NewDatacenterFromDef(def)
+			}
+			s, err := TopoFromFile(f, []model.Datacenter{aDc})
+			if err != nil {
+				return nil, err
+			}
+			datacenters[d] = s[0]
+		}
+	}
+
+	for i, def := range dcinjector.Datacenters {
+		if _, ok := datacenters[def.Name]; !ok {
+			return nil, &FileError{File: file, Problems: []Problem{newLocator(file).problem(file, -1, false, fmt.Sprintf("datacenter %s has no topology", def.Name), "datacenters", i)}}
+		}
+	}
+
+	bp := &Blueprint{Datacenters: []model.Datacenter{}, XDCRSets: []XDCRSet{}}
+	for _, d := range datacenters {
+		bp.Datacenters = append(bp.Datacenters, d)
+	}
+	sort.Sort(model.DatacenterByName(bp.Datacenters))
+
+	for _, f := range sortedKeys(dcinjector.XDCRs) {
+		DCS := []model.Datacenter{}
+		for _, d := range dcinjector.XDCRs[f] {
+			dc, ok := datacenters[d]
+			if !ok {
+				return nil, &FileError{File: file, Problems: []Problem{newLocator(file).problem(file, -1, false, fmt.Sprintf("datacenter %s of %s has no topology", d, f), "xdcrs", f)}}
+			}
+			DCS = append(DCS, dc)
+		}
+		defs, err := XDCRDefsFromFile(f)
+		if err != nil {
+			return nil, err
+		}
+		bp.XDCRSets = append(bp.XDCRSets, XDCRSet{File: f, Definitions: defs, Datacenters: DCS})
+		files = append(files, f)
+	}
+	bp.Files = files
+	return bp, nil
+}
+
+func applyDatacenterDefs(file string, dcdefs []model.DatacenterDef, DCs []model.Datacenter) error {
+	for _, def := range dcdefs {
+		found := false
+		for i := range DCs {
+			if DCs[i].Name == def.Name {
+				DCs[i].ApplyDef(def)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: unknown datacenter %s", file, def.Name)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
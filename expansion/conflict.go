@@ -0,0 +1,180 @@
+package expansion
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// Cycle is a set of buckets replicating to each other, directly or through the others: every bucket of the cycle is
+// written by XDCR and by its own clients, making it active-active.
+type Cycle struct {
+	// Buckets are the paths of the buckets of the strongly connected component, sorted
+	Buckets     []string                            `json:"buckets"`
+	Resolutions map[string]model.ConflictResolution `json:"resolutions"`
+	// ReturnLoop is the longest way back of the cycle: the longest of the shortest loops bringing the mutations of
+	// each bucket back to it. It is not the longest loop of the cycle, which may go through more buckets
+	ReturnLoop  []string        `json:"returnLoop"`
+	Definitions []DefinitionRef `json:"definitions"`
+}
+
+// ConflictIssue is a problem of conflict resolution found on a cycle.
+type ConflictIssue struct {
+	Cycle   Cycle  `json:"cycle"`
+	Message string `json:"message"`
+}
+
+func (c ConflictIssue) String() string {
+	refs := []string{}
+	for _, d := range c.Cycle.Definitions {
+		refs = append(refs, d.String())
+	}
+	return fmt.Sprintf("%s: %s (%s)", strings.Join(c.Cycle.Buckets, ", "), c.Message, strings.Join(refs, ", "))
+}
+
+// Cycles returns the active-active cycles of the blueprint, ordered by their first bucket.
+func Cycles(bp *Blueprint) []Cycle {
+	links := blueprintLinks(bp)
+	buckets := map[string]model.Bucket{}
+	next := map[string][]string{}
+	for _, l := range links {
+		s, d := l.Source.Path(), l.Destination.Path()
+		buckets[s], buckets[d] = l.Source, l.Destination
+		next[s] = append(next[s], d)
+	}
+
+	cycles := []Cycle{}
+	for _, component := range stronglyConnected(next) {
+		if len(component) < 2 {
+			continue
+		}
+		sort.Strings(component)
+		in := map[string]bool{}
+		c := Cycle{Buckets: component, Resolutions: map[string]model.ConflictResolution{}}
+		for _, b := range component {
+			in[b] = true
+			bucket := buckets[b]
+			c.Resolutions[b] = bucket.Resolution()
+		}
+		for _, b := range component {
+			if loop := shortestLoop(b, next, in); len(loop) > len(c.ReturnLoop) {
+				c.ReturnLoop = loop
+			}
+		}
+		inner := []*link{}
+		for _, l := range links {
+			if in[l.Source.Path()] && in[l.Destination.Path()] {
+				inner = append(inner, l)
+			}
+		}
+		c.Definitions = linkDefinitions(inner)
+		cycles = append(cycles, c)
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i].Buckets[0] < cycles[j].Buckets[0] })
+	return cycles
+}
+
+// CheckConflicts flags the cycles mixing conflict resolution types, the ones not using the timestamp based (lww)
+// resolution, and when maxReturnLoop is positive the ones whose ReturnLoop is longer than maxReturnLoop replications.
+func CheckConflicts(bp *Blueprint, maxReturnLoop int) []ConflictIssue {
+	issues := []ConflictIssue{}
+	for _, c := range Cycles(bp) {
+		count := map[model.ConflictResolution]int{}
+		for _, r := range c.Resolutions {
+			count[r]++
+		}
+		switch {
+		case len(count) > 1:
+			issues = append(issues, ConflictIssue{Cycle: c, Message: fmt.Sprintf("mismatched conflict resolutions: %d seqno, %d lww", count[model.SeqnoConflictResolution], count[model.LWWConflictResolution])})
+		case count[model.LWWConflictResolution] == 0:
+			issues = append(issues, ConflictIssue{Cycle: c, Message: "active-active cycle without timestamp based (lww) conflict resolution"})
+		}
+		if maxReturnLoop > 0 && len(c.ReturnLoop) > maxReturnLoop {
+			loop := strings.Join(append(c.ReturnLoop, c.ReturnLoop[0]), " -> ")
+			issues = append(issues, ConflictIssue{Cycle: c, Message: fmt.Sprintf("the mutations of %s come back to it through at least %d replications (%s), expected at most %d", c.ReturnLoop[0], len(c.ReturnLoop), loop, maxReturnLoop)})
+		}
+	}
+	return issues
+}
+
+// stronglyConnected returns the strongly connected components of the graph (Tarjan).
+func stronglyConnected(next map[string][]string) [][]string {
+	nodes := []string{}
+	for n := range next {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	stack := []string{}
+	components := [][]string{}
+
+	var visit func(n string)
+	visit = func(n string) {
+		index[n] = len(index)
+		low[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, m := range next[n] {
+			if _, ok := index[m]; !ok {
+				visit(m)
+				if low[m] < low[n] {
+					low[n] = low[m]
+				}
+			} else if onStack[m] && index[m] < low[n] {
+				low[n] = index[m]
+			}
+		}
+		if low[n] == index[n] {
+			component := []string{}
+			for {
+				m := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[m] = false
+				component = append(component, m)
+				if m == n {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, n := range nodes {
+		if _, ok := index[n]; !ok {
+			visit(n)
+		}
+	}
+	return components
+}
+
+// shortestLoop returns the buckets of the shortest loop from start back to start, staying in the component.
+func shortestLoop(start string, next map[string][]string, in map[string]bool) []string {
+	parent := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, m := range next[n] {
+			if !in[m] {
+				continue
+			}
+			if m == start {
+				loop := []string{}
+				for c := n; c != start; c = parent[c] {
+					loop = append([]string{c}, loop...)
+				}
+				return append([]string{start}, loop...)
+			}
+			if _, seen := parent[m]; !seen {
+				parent[m] = n
+				queue = append(queue, m)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package expansion
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func TestStronglyConnected(t *testing.T) {
+	tests := []struct {
+		name string
+		next map[string][]string
+		want [][]string
+	}{
+		{"chain", map[string][]string{"a": {"b"}, "b": {"c"}}, [][]string{{"a"}, {"b"}, {"c"}}},
+		{"pair", map[string][]string{"a": {"b"}, "b": {"a"}}, [][]string{{"a", "b"}}},
+		{"loop and tail", map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a", "d"}, "d": {"e"}}, [][]string{{"a", "b", "c"}, {"d"}, {"e"}}},
+		{"two loops", map[string][]string{"a": {"b"}, "b": {"a", "c"}, "c": {"d"}, "d": {"c"}}, [][]string{{"a", "b"}, {"c", "d"}}},
+		{"joined loops", map[string][]string{"a": {"b"}, "b": {"a", "c"}, "c": {"d"}, "d": {"b"}}, [][]string{{"a", "b", "c", "d"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stronglyConnected(tt.next)
+			for _, c := range got {
+				sort.Strings(c)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i][0] < got[j][0] })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stronglyConnected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortestLoop(t *testing.T) {
+	// a -> b -> c -> d -> a, and the shortcut b -> a
+	next := map[string][]string{"a": {"b"}, "b": {"c", "a"}, "c": {"d"}, "d": {"a", "e"}, "e": {"c"}}
+	in := map[string]bool{"a": true, "b": true, "c": true, "d": true}
+	tests := []struct {
+		start string
+		want  []string
+	}{
+		{"a", []string{"a", "b"}},
+		{"b", []string{"b", "a"}},
+		// e is not in the component, d -> e -> c is not a loop of it
+		{"c", []string{"c", "d", "a", "b"}},
+		{"e", nil},
+	}
+	for _, tt := range tests {
+		if got := shortestLoop(tt.start, next, in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shortestLoop(%s) = %v, want %v", tt.start, got, tt.want)
+		}
+	}
+}
+
+func TestCycles(t *testing.T) {
+	buckets := []model.Bucket{testBucket("DC1", "A"), testBucket("DC1", "B"), testBucket("DC1", "C"), testBucket("DC1", "D")}
+	if got := Cycles(testBlueprint(buckets, "A->B", "B->C", "A->C")); len(got) != 0 {
+		t.Errorf("Cycles() = %v, want none", got)
+	}
+
+	got := Cycles(testBlueprint(buckets, "A->B", "B->C", "C->A", "C->D", "B->A"))
+	if len(got) != 1 {
+		t.Fatalf("Cycles() = %v, want one cycle", got)
+	}
+	c := got[0]
+	if want := []string{"DC1___A", "DC1___B", "DC1___C"}; !reflect.DeepEqual(c.Buckets, want) {
+		t.Errorf("Cycles() buckets = %v, want %v", c.Buckets, want)
+	}
+	// the shortest loop through C is the longest one
+	if want := []string{"DC1___C", "DC1___A", "DC1___B"}; !reflect.DeepEqual(c.ReturnLoop, want) {
+		t.Errorf("Cycles() return loop = %v, want %v", c.ReturnLoop, want)
+	}
+	// in the order of the replications, C -> D is not one of the cycle
+	refs := []DefinitionRef{{"XDCR.yaml", 0}, {"XDCR.yaml", 4}, {"XDCR.yaml", 1}, {"XDCR.yaml", 2}}
+	if !reflect.DeepEqual(c.Definitions, refs) {
+		t.Errorf("Cycles() definitions = %v, want %v", c.Definitions, refs)
+	}
+}
+
+func TestCheckConflicts(t *testing.T) {
+	lww := func(b model.Bucket) model.Bucket {
+		b.ConflictResolution = model.LWWConflictResolution
+		return b
+	}
+	tests := []struct {
+		name    string
+		buckets []model.Bucket
+		edges   []string
+		maxLoop int
+		want    []string
+	}{
+		{"no cycle", []model.Bucket{testBucket("DC1", "A"), testBucket("DC1", "B")}, []string{"A->B"}, 0, []string{}},
+		{"lww cycle", []model.Bucket{lww(testBucket("DC1", "A")), lww(testBucket("DC1", "B"))}, []string{"A->B", "B->A"}, 0, []string{}},
+		{"seqno cycle", []model.Bucket{testBucket("DC1", "A"), testBucket("DC1", "B")}, []string{"A->B", "B->A"}, 0,
+			[]string{"active-active cycle without timestamp based (lww) conflict resolution"}},
+		{"mismatched cycle", []model.Bucket{lww(testBucket("DC1", "A")), testBucket("DC1", "B")}, []string{"A->B", "B->A"}, 0,
+			[]string{"mismatched conflict resolutions: 1 seqno, 1 lww"}},
+		{"short loop", []model.Bucket{lww(testBucket("DC1", "A")), lww(testBucket("DC1", "B")), lww(testBucket("DC1", "C"))}, []string{"A->B", "B->C", "C->A"}, 3, []string{}},
+		{"long loop", []model.Bucket{lww(testBucket("DC1", "A")), lww(testBucket("DC1", "B")), lww(testBucket("DC1", "C"))}, []string{"A->B", "B->C", "C->A"}, 2,
+			[]string{"the mutations of DC1___A come back to it through at least 3 replications (DC1___A -> DC1___B -> DC1___C -> DC1___A), expected at most 2"}},
+		// A and B are on a loop of 2 replications, C only on the one of 3
+		{"longest way back", []model.Bucket{lww(testBucket("DC1", "A")), lww(testBucket("DC1", "B")), lww(testBucket("DC1", "C"))}, []string{"A->B", "B->A", "B->C", "C->A"}, 2,
+			[]string{"the mutations of DC1___C come back to it through at least 3 replications (DC1___C -> DC1___A -> DC1___B -> DC1___C), expected at most 2"}},
+		{"uppercase", []model.Bucket{lww(testBucket("DC1", "A")), {Name: "B", Labels: model.Labels{"Datacenter": "DC1", "Bucket": "B"}, ConflictResolution: "LWW"}}, []string{"A->B", "B->A"}, 0, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, issue := range CheckConflicts(testBlueprint(tt.buckets, tt.edges...), tt.maxLoop) {
+				got = append(got, issue.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckConflicts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package expansion
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+	"github.com/dbenque/couchbaseblueprint/rules"
+)
+
+// Explanation reports, for a pair of buckets, why each XDCR definition of a blueprint does or does not replicate
+// the source bucket to the destination bucket.
+type Explanation struct {
+	Source      string                        `json:"source"`
+	Destination string                        `json:"destination"`
+	Produced    bool                          `json:"produced"`
+	Definitions []rules.DefinitionExplanation `json:"definitions"`
+}
+
+func Explain(bp *Blueprint, source, destination string) (*Explanation, error) {
+	if _, ok := model.FindBucket(bp.Datacenters, source); !ok {
+		return nil, fmt.Errorf("Unknown source bucket %s", source)
+	}
+	if _, ok := model.FindBucket(bp.Datacenters, destination); !ok {
+		return nil, fmt.Errorf("Unknown destination bucket %s", destination)
+	}
+
+	e := &Explanation{Source: source, Destination: destination, Definitions: []rules.DefinitionExplanation{}}
+	for _, set := range bp.XDCRSets {
+		for i, def := range set.Definitions {
+			de := rules.ExplainDefinition(def, set.Datacenters, source, destination)
+			de.File = set.File
+			de.Index = i
+			e.Produced = e.Produced || de.Produced
+			e.Definitions = append(e.Definitions, de)
+		}
+	}
+	return e, nil
+}
+
+func (e *Explanation) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%s -> %s: ", e.Source, e.Destination)
+	if e.Produced {
+		fmt.Fprintf(w, "produced\n")
+	} else {
+		fmt.Fprintf(w, "not produced\n")
+	}
+	for _, de := range e.Definitions {
+		fmt.Fprintf(w, "\n%s #%d rule=%s produced=%t\n", de.File, de.Index, de.Definition.Rule, de.Produced)
+		for _, t := range de.Terms {
+			status := "ok"
+			if !t.Matched {
+				status = "no match"
+			}
+			actual := t.Actual
+			if !t.Present {
+				actual = "<missing>"
+			}
+			fmt.Fprintf(w, "  %s.%s %s=%s actual=%s %s\n", t.Bucket, t.Selector, t.Key, t.Expected, actual, status)
+		}
+		for _, s := range de.Steps {
+			fmt.Fprintf(w, "  - %s\n", s)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package expansion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// DCInjector maps the topology and XDCR files of a DC file to the datacenters they apply to. Datacenters optionally
+// gives the labels and the overrides of each datacenter.
+type DCInjector struct {
+	model.Header `yaml:",inline"`
+	Topos        map[string][]string
+	XDCRs        map[string][]string
+	Datacenters  []model.DatacenterDef `yaml:",omitempty" json:",omitempty"`
+}
+
+// ToFile writes v in filePath.json and filePath.yaml.
+func ToFile(v interface{}, filePath string) error {
+	//json
+	{
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var out bytes.Buffer
+		json.Indent(&out, b, " ", "\t")
+		if err := ioutil.WriteFile(filePath+".json", out.Bytes(), 0777); err != nil {
+			return err
+		}
+	}
+	//yaml
+	{
+		y, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filePath+".yaml", y, 0777); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalFile decodes the file after upgrading it to the latest API version. The toml and hcl files are converted to
+// json first.
+func unmarshalFile(file string, v interface{}) error {
+	format, b, err := readFile(file)
+	if err != nil {
+		return err
+	}
+	converted := format == TOMLFormat || format == HCLFormat
+	if converted {
+		source := b
+		if b, err = toJSON(format, b, v); err != nil {
+			return convertError(file, source, err)
+		}
+		format = JSONFormat
+	} else if notMapping(b) {
+		return &FileError{File: file, Problems: []Problem{{File: file, Index: -1, Message: fmt.Sprintf("no blueprint found in the %s content, expected one of the formats %v", format, FormatNames())}}}
+	}
+	original := b
+	b, origin, err := upgradeFile(file, format, b, fileKind(v))
+	if fe, ok := err.(*FileError); ok && converted {
+		// the positions are the ones of the converted json
+		unposition(fe)
+		return fe
+	} else if err != nil {
+		return err
+	}
+	if !converted {
+		// the decoders do not position the errors of the selectors, they are checked on the original content
+		l := &locator{lines: strings.Split(string(original), "\n")}
+		var root yaml3.Node
+		if yaml3.Unmarshal(original, &root) == nil && len(root.Content) > 0 {
+			l.root = root.Content[0]
+		}
+		if problems := selectorProblems(file, l, reflect.TypeOf(v)); len(problems) > 0 {
+			return &FileError{File: file, Problems: problems}
+		}
+	}
+	// unknown keys are errors, they are most often misspelled ones
+	switch format {
+	case JSONFormat:
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		err = d.Decode(v)
+	case YAMLFormat:
+		err = yaml.UnmarshalStrict(b, v)
+	}
+	if err != nil {
+		fe := decodeError(file, b, err).(*FileError)
+		if converted {
+			unposition(fe)
+		} else if origin != nil {
+			origin.restore(fe, original)
+		}
+		return fe
+	}
+	return nil
+}
+
+// unposition removes the position of the problems, for the contents that are not the ones the user wrote.
+func unposition(fe *FileError) {
+	for i := range fe.Problems {
+		fe.Problems[i].Line, fe.Problems[i].Column, fe.Problems[i].Snippet = 0, 0, ""
+	}
+}
+
+// TopoFromFile adds the cluster groups defined in file to each of the datacenters.
+func TopoFromFile(file string, DCs []model.Datacenter) ([]model.Datacenter, error) {
+	var cgdefBlueprint model.ClusterGroupDefBluePrint
+	if err := unmarshalFile(file, &cgdefBlueprint); err != nil {
+		return nil, err
+	}
+
+	for _, d := range cgdefBlueprint.ClusterGroups {
+		for i := range DCs {
+			DCs[i].AddClusterGroupDef(d)
+		}
+	}
+
+	return DCs, nil
+}
+
+// DatacenterDefsFromFile reads the labels and the overrides of the datacenters.
+func DatacenterDefsFromFile(file string) ([]model.DatacenterDef, error) {
+	var dcdefBlueprint model.DatacenterDefBluePrint
+	if err := unmarshalFile(file, &dcdefBlueprint); err != nil {
+		return nil, err
+	}
+	return dcdefBlueprint.Datacenters, nil
+}
+
+func XDCRDefsFromFile(file string) ([]model.XDCRDef, error) {
+	var xdcrdefBlueprint model.XDCRDefBluePrint
+	if err := unmarshalFile(file, &xdcrdefBlueprint); err != nil {
+		return nil, err
+	}
+	return xdcrdefBlueprint.XDCRDefs, nil
+}
@@ -0,0 +1,261 @@
+package expansion
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl"
+	hclparser "github.com/hashicorp/hcl/hcl/parser"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// Formats of the input files.
+const (
+	YAMLFormat = "yaml"
+	JSONFormat = "json"
+	TOMLFormat = "toml"
+	HCLFormat  = "hcl"
+)
+
+// Stdin is the file name standing for the standard input.
+const Stdin = "-"
+
+// formatExtensions maps the file extensions to their format, the first extension of a format is the one it is written
+// with.
+var formatExtensions = []struct {
+	Extension string
+	Format    string
+}{
+	{".yaml", YAMLFormat},
+	{".yml", YAMLFormat},
+	{".json", JSONFormat},
+	{".toml", TOMLFormat},
+	{".hcl", HCLFormat},
+}
+
+// FormatNames returns the supported input formats.
+func FormatNames() []string {
+	return []string{YAMLFormat, JSONFormat, TOMLFormat, HCLFormat}
+}
+
+// FormatFromExtension returns the format of a file name, empty when the extension is unknown.
+func FormatFromExtension(file string) string {
+	ext := strings.ToLower(filepath.Ext(file))
+	for _, f := range formatExtensions {
+		if f.Extension == ext {
+			return f.Format
+		}
+	}
+	return ""
+}
+
+// FormatExtension returns the extension the files of a format are written with.
+func FormatExtension(format string) string {
+	for _, f := range formatExtensions {
+		if f.Format == format {
+			return f.Extension
+		}
+	}
+	return ""
+}
+
+var (
+	tomlTable      = regexp.MustCompile(`(?m)^\s*\[\[?[A-Za-z0-9_."-]+\]\]?\s*(#.*)?$`)
+	tomlAssignment = regexp.MustCompile(`(?m)^\s*[A-Za-z0-9_"-]+\s*=\s*\S`)
+	hclBlock       = regexp.MustCompile(`(?m)^\s*[A-Za-z0-9_"-]+\s*\{\s*(#.*)?$`)
+)
+
+// SniffFormat guesses the format of a content: json when it starts with an object, hcl when it has blocks, toml when
+// it has tables or assignments, yaml otherwise. A yaml mapping that is not valid hcl or toml is yaml, its block
+// scalars may hold lines looking like blocks or assignments.
+func SniffFormat(b []byte) string {
+	trimmed := bytes.TrimSpace(b)
+	var m map[string]interface{}
+	switch {
+	case len(trimmed) > 0 && trimmed[0] == '{':
+		return JSONFormat
+	case hclBlock.Match(b):
+		if hcl.Unmarshal(b, &m) != nil && isMapping(b) {
+			return YAMLFormat
+		}
+		return HCLFormat
+	case tomlTable.Match(b) || tomlAssignment.Match(b):
+		if toml.Unmarshal(b, &m) != nil && isMapping(b) {
+			return YAMLFormat
+		}
+		return TOMLFormat
+	}
+	return YAMLFormat
+}
+
+// DetectFormat returns the format given by the extension of the file, or sniffed from its content when the extension
+// is unknown, as for the standard input.
+func DetectFormat(file string, b []byte) string {
+	if f := FormatFromExtension(file); f != "" {
+		return f
+	}
+	return SniffFormat(b)
+}
+
+var stdinContent []byte
+
+// readFile returns the format and the content of a file, Stdin being read once.
+func readFile(file string) (string, []byte, error) {
+	var b []byte
+	var err error
+	if file == Stdin {
+		if stdinContent == nil {
+			if stdinContent, err = ioutil.ReadAll(os.Stdin); err != nil {
+				return "", nil, err
+			}
+		}
+		b = stdinContent
+	} else if b, err = ioutil.ReadFile(file); err != nil {
+		return "", nil, err
+	}
+	return DetectFormat(file, b), b, nil
+}
+
+// FindFile returns the file of the folder named base with the extension of one of the formats, or of format when it
+// is set.
+func FindFile(folder, base, format string) (string, error) {
+	for _, f := range formatExtensions {
+		if format != "" && f.Format != format {
+			continue
+		}
+		path := filepath.Join(folder, base+f.Extension)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	if format != "" {
+		return "", fmt.Errorf("%s: no %s.%s file", folder, base, format)
+	}
+	return "", fmt.Errorf("%s: no %s file, expected one of the formats %v", folder, base, FormatNames())
+}
+
+// toJSON converts a toml or hcl content to json, shaped after v so that it is decoded like a json file.
+func toJSON(format string, b []byte, v interface{}) ([]byte, error) {
+	var m map[string]interface{}
+	switch format {
+	case TOMLFormat:
+		if err := toml.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+	case HCLFormat:
+		if err := hcl.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return json.Marshal(shape(m, reflect.TypeOf(v)))
+}
+
+// shape adapts a decoded value to the type it is decoded into: hcl decodes every object as a list of objects, a list
+// of one object is unwrapped when an object is expected.
+func shape(v interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if l, ok := v.([]map[string]interface{}); ok {
+		items := []interface{}{}
+		for _, m := range l {
+			items = append(items, m)
+		}
+		v = items
+	}
+	if l, ok := v.([]interface{}); ok && len(l) == 1 && (t.Kind() == reflect.Map || t.Kind() == reflect.Struct) {
+		v = l[0]
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		if l, ok := v.([]interface{}); ok {
+			for i := range l {
+				l[i] = shape(l[i], t.Elem())
+			}
+			return l
+		}
+	case reflect.Map:
+		if m, ok := v.(map[string]interface{}); ok {
+			for k := range m {
+				m[k] = shape(m[k], t.Elem())
+			}
+			return m
+		}
+	case reflect.Struct:
+		if m, ok := v.(map[string]interface{}); ok {
+			for k := range m {
+				if f, ok := fieldByKey(t, k); ok {
+					m[k] = shape(m[k], f.Type)
+				}
+			}
+			return m
+		}
+	}
+	return v
+}
+
+// fieldByKey returns the field of the struct decoded from key, including the fields of inlined structs.
+func fieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && strings.Contains(f.Tag.Get("yaml"), "inline") {
+			if inner, ok := fieldByKey(f.Type, key); ok {
+				return inner, true
+			}
+			continue
+		}
+		if name, ok := yamlKey(f); ok && strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// convertError returns the problem of a toml or hcl content that can not be converted, positioned when the parser
+// gives the position of the error.
+func convertError(file string, b []byte, err error) *FileError {
+	p := Problem{File: file, Index: -1, Message: err.Error()}
+	var tomlErr toml.ParseError
+	var hclErr *hclparser.PosError
+	switch {
+	case errors.As(err, &tomlErr):
+		p.Line, p.Column, p.Message = tomlErr.Position.Line, tomlErr.Position.Col, tomlErr.Message
+	case errors.As(err, &hclErr):
+		p.Line, p.Column, p.Message = hclErr.Pos.Line, hclErr.Pos.Column, hclErr.Err.Error()
+	}
+	if p.Line > 0 {
+		if p.Column < 1 {
+			p.Column = 1
+		}
+		p.Snippet = (&locator{lines: strings.Split(string(b), "\n")}).snippet(p.Line)
+	}
+	return &FileError{File: file, Problems: []Problem{p}}
+}
+
+// notMapping tells whether the content parses but is not a mapping, as an empty file or a file of another format.
+func notMapping(b []byte) bool {
+	var root yaml3.Node
+	if err := yaml3.Unmarshal(b, &root); err != nil {
+		// the decoding reports the syntax error
+		return false
+	}
+	return len(root.Content) == 0 || root.Content[0].Kind != yaml3.MappingNode
+}
+
+// isMapping tells whether the content is a yaml mapping.
+func isMapping(b []byte) bool {
+	var root yaml3.Node
+	return yaml3.Unmarshal(b, &root) == nil && len(root.Content) > 0 && root.Content[0].Kind == yaml3.MappingNode
+}
@@ -0,0 +1,56 @@
+package expansion
+
+import (
+	"testing"
+)
+
+func TestSniffFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "", YAMLFormat},
+		{"json", "\n  {\"policies\": []}\n", JSONFormat},
+		{"yaml", "apiVersion: couchbaseblueprint/v2\nkind: Policy\npolicies:\n- name: a\n", YAMLFormat},
+		{"yaml list", "- a\n- b\n", YAMLFormat},
+		{"yaml block scalar with assignments", "kind: Style\ndescription: |\n  role=resa\n  level = 2\nnodes: []\n", YAMLFormat},
+		{"yaml block scalar with a table", "description: >\n  [table]\n  key = value\n", YAMLFormat},
+		{"yaml block scalar with a block", "description: |\n  labels {\n  }\n", YAMLFormat},
+		{"toml assignments", "apiVersion = \"couchbaseblueprint/v2\"\nkind = \"Policy\"\n", TOMLFormat},
+		{"toml tables", "[[policies]]\nname = \"a\"\nminInbound = 2\n", TOMLFormat},
+		{"invalid toml", "kind = \"Policy\"\nname = \n", TOMLFormat},
+		{"hcl", "policies {\n  name = \"a\"\n}\n", HCLFormat},
+		{"invalid hcl", "policies {\n  name = \n", HCLFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SniffFormat([]byte(tt.content)); got != tt.want {
+				t.Errorf("SniffFormat(%q) = %s, want %s", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertError(t *testing.T) {
+	tests := []struct {
+		format  string
+		content string
+		line    int
+		snippet string
+	}{
+		{TOMLFormat, "kind = \"Policy\"\n[[policies]]\nname = \n", 3, "name = "},
+		{HCLFormat, "kind = \"Policy\"\npolicies {\n  name = = \"a\"\n}\n", 3, "  name = = \"a\""},
+	}
+	for _, tt := range tests {
+		_, err := toJSON(tt.format, []byte(tt.content), &PolicyBluePrint{})
+		if err == nil {
+			t.Fatalf("toJSON(%s) converted %q", tt.format, tt.content)
+		}
+		fe := convertError("policies."+tt.format, []byte(tt.content), err)
+		p := fe.Problems[0]
+		if p.Line != tt.line || p.Column < 1 || p.Snippet != tt.snippet {
+			t.Errorf("convertError(%s) = %d:%d %q, want line %d and the snippet %q", tt.format, p.Line, p.Column, p.Snippet, tt.line, tt.snippet)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package expansion
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// Link is the aggregate of the replications from one cluster, or datacenter, to another. Each link needs a remote
+// cluster reference and carries the traffic of its replicated buckets.
+type Link struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// Replications is the number of replications of the link
+	Replications int `json:"replications"`
+	// RamQuota is the total RAM quota of the replicated source buckets
+	RamQuota int `json:"ramQuota"`
+}
+
+// Physical is the physical view of the replications: the links between the Cluster instances and between the
+// datacenters, the replications within a cluster or within a datacenter are not links of that level.
+type Physical struct {
+	Clusters    []Link `json:"clusters"`
+	Datacenters []Link `json:"datacenters"`
+}
+
+// PhysicalLevels are the levels the links are aggregated at.
+var PhysicalLevels = []model.BoxKind{model.ClusterBox, model.DatacenterBox}
+
+// NewPhysical aggregates the replications into links, sorted by source and destination.
+func NewPhysical(xdcrs []model.XDCR) *Physical {
+	return &Physical{Clusters: aggregateLinks(xdcrs, clusterOf), Datacenters: aggregateLinks(xdcrs, datacenterOf)}
+}
+
+// Links returns the links of the level.
+func (p *Physical) Links(level model.BoxKind) ([]Link, error) {
+	switch level {
+	case model.ClusterBox:
+		return p.Clusters, nil
+	case model.DatacenterBox:
+		return p.Datacenters, nil
+	}
+	return nil, fmt.Errorf("Invalid level %q, expected one of %v", level, PhysicalLevels)
+}
+
+// clusterOf returns the path of the cluster of a bucket, as Cluster.Path does.
+func clusterOf(b *model.Bucket) string {
+	return b.Labels["Datacenter"] + "_" + b.Labels["ClusterGroup"] + "_" + b.Labels["Cluster"]
+}
+
+func datacenterOf(b *model.Bucket) string {
+	return b.Labels["Datacenter"]
+}
+
+func aggregateLinks(xdcrs []model.XDCR, node func(*model.Bucket) string) []Link {
+	byPair := map[[2]string]*Link{}
+	for _, x := range xdcrs {
+		s, d := node(&x.Source), node(&x.Destination)
+		if s == d {
+			continue
+		}
+		l, ok := byPair[[2]string{s, d}]
+		if !ok {
+			l = &Link{Source: s, Destination: d}
+			byPair[[2]string{s, d}] = l
+		}
+		l.Replications++
+		l.RamQuota += x.Source.RamQuota
+	}
+	links := []Link{}
+	for _, l := range byPair {
+		links = append(links, *l)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Source != links[j].Source {
+			return links[i].Source < links[j].Source
+		}
+		return links[i].Destination < links[j].Destination
+	})
+	return links
+}
+
+// PhysicalGraph returns the datacenters folded at the level, cluster or datacenter, and one replication per link
+// labelled with its number of replications and RAM quota. The datacenters must be the ones of the replications.
+func PhysicalGraph(dcs []model.Datacenter, xdcrs []model.XDCR, level model.BoxKind) ([]model.Datacenter, []model.XDCR, error) {
+	if level != model.ClusterBox && level != model.DatacenterBox {
+		return nil, nil, fmt.Errorf("Invalid level %q, expected one of %v", level, PhysicalLevels)
+	}
+	dcs, nodes := collapseDatacenters(dcs, level)
+	return dcs, aggregateXDCRs(xdcrs, nodes, func(l Link) string {
+		return fmt.Sprintf("%d xdcr, ram %d", l.Replications, l.RamQuota)
+	}), nil
+}
+
+// WriteLinks writes links as a table, json or csv.
+func WriteLinks(w io.Writer, format string, links []Link) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "SOURCE\tDESTINATION\tREPLICATIONS\tRAMQUOTA\n")
+		for _, l := range links {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", l.Source, l.Destination, l.Replications, l.RamQuota)
+		}
+		return tw.Flush()
+	case "json":
+		b, err := json.MarshalIndent(links, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"source", "destination", "replications", "ramQuota"})
+		for _, l := range links {
+			cw.Write([]string{l.Source, l.Destination, strconv.Itoa(l.Replications), strconv.Itoa(l.RamQuota)})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("Unknown links output %q, expected table, json or csv", format)
+}
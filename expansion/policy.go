@@ -0,0 +1,228 @@
+package expansion
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// PolicyBluePrint is the content of a policy file.
+type PolicyBluePrint struct {
+	model.Header `yaml:",inline"`
+	Policies     []Policy
+}
+
+// Policy is an organisation rule enforced on the expanded buckets and replications. The checks left to their zero
+// value are disabled.
+//
+//	policies:
+//	- name: resa-redundancy
+//	  buckets: {Role: Resa}
+//	  minInbound: 2
+//	  inboundDifferentOn: [Datacenter]
+//	- name: no-prod-to-test
+//	  forbidSource: {Environment: prod}
+//	  forbidDestination: {Environment: test}
+//	  bidirectional: true
+type Policy struct {
+	Name string `yaml:"name" json:"name"`
+	// Buckets selects the buckets checked by the policy, all of them when empty
+	Buckets model.Selector `yaml:"buckets,omitempty" json:"buckets,omitempty"`
+	// MinInbound is the minimum number of replications toward each bucket, only the replications from a source having
+	// different values for the InboundDifferentOn labels are counted
+	MinInbound         int      `yaml:"minInbound,omitempty" json:"minInbound,omitempty"`
+	InboundDifferentOn []string `yaml:"inboundDifferentOn,omitempty" json:"inboundDifferentOn,omitempty"`
+	// MaxFanOut is the maximum number of replications from each bucket
+	MaxFanOut int `yaml:"maxFanOut,omitempty" json:"maxFanOut,omitempty"`
+	// MinReplicas is the minimum CBReplicateNumber of each bucket
+	MinReplicas int `yaml:"minReplicas,omitempty" json:"minReplicas,omitempty"`
+	// ForbidSource and ForbidDestination forbid the replications between the matching buckets, in both directions
+	// when Bidirectional is set. An empty selector matches every bucket.
+	ForbidSource      model.Selector `yaml:"forbidSource,omitempty" json:"forbidSource,omitempty"`
+	ForbidDestination model.Selector `yaml:"forbidDestination,omitempty" json:"forbidDestination,omitempty"`
+	Bidirectional     bool           `yaml:"bidirectional,omitempty" json:"bidirectional,omitempty"`
+}
+
+// DefinitionRef locates an XDCR definition.
+type DefinitionRef struct {
+	File  string `json:"file"`
+	Index int    `json:"index"`
+}
+
+func (d DefinitionRef) String() string {
+	return fmt.Sprintf("%s #%d", d.File, d.Index)
+}
+
+// Violation is a bucket or a replication breaking a policy, with the definitions producing the replications involved.
+type Violation struct {
+	Policy      string          `json:"policy"`
+	Bucket      string          `json:"bucket"`
+	Message     string          `json:"message"`
+	Definitions []DefinitionRef `json:"definitions,omitempty"`
+}
+
+func (v Violation) String() string {
+	s := fmt.Sprintf("%s: %s: %s", v.Policy, v.Bucket, v.Message)
+	if len(v.Definitions) > 0 {
+		refs := []string{}
+		for _, d := range v.Definitions {
+			refs = append(refs, d.String())
+		}
+		s += " (" + strings.Join(refs, ", ") + ")"
+	}
+	return s
+}
+
+// PoliciesFromFile reads a policy file.
+func PoliciesFromFile(file string) ([]Policy, error) {
+	var policyBlueprint PolicyBluePrint
+	if err := unmarshalFile(file, &policyBlueprint); err != nil {
+		return nil, err
+	}
+	l := newLocator(file)
+	fe := &FileError{File: file}
+	for i, p := range policyBlueprint.Policies {
+		if p.Name == "" {
+			fe.Problems = append(fe.Problems, l.problem(file, i, false, "policy has no name", "policies", i))
+		}
+		if (p.ForbidSource == nil) != (p.ForbidDestination == nil) {
+			fe.Problems = append(fe.Problems, l.problem(file, i, false, "policy needs both forbidSource and forbidDestination", "policies", i))
+		}
+	}
+	if len(fe.Problems) > 0 {
+		return nil, fe
+	}
+	return policyBlueprint.Policies, nil
+}
+
+// link is a replication of the blueprint with the definitions producing it.
+type link struct {
+	Source      model.Bucket
+	Destination model.Bucket
+	Definitions []DefinitionRef
+}
+
+// blueprintLinks returns the distinct replications of the blueprint sorted by source and destination path.
+func blueprintLinks(bp *Blueprint) []*link {
+	byKey := map[string]*link{}
+	keys := []string{}
+	for _, set := range bp.XDCRSets {
+		for i, xdcrs := range set.Replications() {
+			ref := DefinitionRef{File: set.File, Index: i}
+			for _, x := range xdcrs {
+				k := x.Source.Path() + "\x00" + x.Destination.Path()
+				l, ok := byKey[k]
+				if !ok {
+					l = &link{Source: x.Source, Destination: x.Destination}
+					byKey[k] = l
+					keys = append(keys, k)
+				}
+				if n := len(l.Definitions); n == 0 || l.Definitions[n-1] != ref {
+					l.Definitions = append(l.Definitions, ref)
+				}
+			}
+		}
+	}
+	sort.Strings(keys)
+	result := []*link{}
+	for _, k := range keys {
+		result = append(result, byKey[k])
+	}
+	return result
+}
+
+// CheckPolicies evaluates the policies against the expanded buckets and replications of the blueprint.
+func CheckPolicies(bp *Blueprint, policies []Policy) []Violation {
+	violations := []Violation{}
+	links := blueprintLinks(bp)
+	buckets := model.BucketByPath{}
+	for _, dc := range bp.Datacenters {
+		buckets = append(buckets, dc.GetBuckets()...)
+	}
+	sort.Sort(buckets)
+
+	inbound := map[string][]*link{}
+	outbound := map[string][]*link{}
+	for _, l := range links {
+		inbound[l.Destination.Path()] = append(inbound[l.Destination.Path()], l)
+		outbound[l.Source.Path()] = append(outbound[l.Source.Path()], l)
+	}
+
+	for _, p := range policies {
+		report := func(bucket string, refs []DefinitionRef, format string, a ...interface{}) {
+			violations = append(violations, Violation{Policy: p.Name, Bucket: bucket, Message: fmt.Sprintf(format, a...), Definitions: refs})
+		}
+
+		for _, b := range buckets {
+			if !p.selects(b) {
+				continue
+			}
+			if p.MinReplicas > 0 && b.CBReplicateNumber < p.MinReplicas {
+				report(b.Path(), nil, "cbReplicaNumber is %d, expected at least %d", b.CBReplicateNumber, p.MinReplicas)
+			}
+			if p.MinInbound > 0 {
+				counted := []*link{}
+				for _, l := range inbound[b.Path()] {
+					if differentOn(l.Source, b, p.InboundDifferentOn) {
+						counted = append(counted, l)
+					}
+				}
+				if len(counted) < p.MinInbound {
+					from := ""
+					if len(p.InboundDifferentOn) > 0 {
+						from = fmt.Sprintf(" from a different %v", p.InboundDifferentOn)
+					}
+					report(b.Path(), linkDefinitions(counted), "%d inbound replication(s)%s, expected at least %d", len(counted), from, p.MinInbound)
+				}
+			}
+			if p.MaxFanOut > 0 && len(outbound[b.Path()]) > p.MaxFanOut {
+				report(b.Path(), linkDefinitions(outbound[b.Path()]), "%d outbound replications, expected at most %d", len(outbound[b.Path()]), p.MaxFanOut)
+			}
+		}
+
+		if p.ForbidSource != nil && p.ForbidDestination != nil {
+			for _, l := range links {
+				if !p.selects(l.Source) && !p.selects(l.Destination) {
+					continue
+				}
+				forbidden := l.Source.Match(p.ForbidSource) && l.Destination.Match(p.ForbidDestination)
+				if p.Bidirectional {
+					forbidden = forbidden || (l.Source.Match(p.ForbidDestination) && l.Destination.Match(p.ForbidSource))
+				}
+				if forbidden {
+					report(l.Source.Path(), l.Definitions, "forbidden replication to %s", l.Destination.Path())
+				}
+			}
+		}
+	}
+	return violations
+}
+
+func (p Policy) selects(b model.Bucket) bool {
+	return len(p.Buckets) == 0 || b.Match(p.Buckets)
+}
+
+func differentOn(a, b model.Bucket, labels []string) bool {
+	for _, l := range labels {
+		if a.Labels[l] == b.Labels[l] {
+			return false
+		}
+	}
+	return true
+}
+
+func linkDefinitions(links []*link) []DefinitionRef {
+	seen := map[DefinitionRef]bool{}
+	result := []DefinitionRef{}
+	for _, l := range links {
+		for _, d := range l.Definitions {
+			if !seen[d] {
+				seen[d] = true
+				result = append(result, d)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,147 @@
+package expansion
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// testBucket returns a bucket of the datacenter labeled with its name as Bucket and with the labels given as key,
+// value pairs.
+func testBucket(dc, name string, labels ...string) model.Bucket {
+	b := model.Bucket{Name: name, Labels: model.Labels{"Datacenter": dc, "Bucket": name}}
+	for i := 0; i+1 < len(labels); i += 2 {
+		b.Labels[labels[i]] = labels[i+1]
+	}
+	return b
+}
+
+// testBlueprint returns a blueprint of the buckets and of one definition per edge, written "A->B" with the bucket
+// names.
+func testBlueprint(buckets []model.Bucket, edges ...string) *Blueprint {
+	dcs := []model.Datacenter{{Name: "DC", ClusterGroups: []model.ClusterGroup{{Clusters: []model.Cluster{{Buckets: buckets}}}}}}
+	defs := []model.XDCRDef{}
+	for _, e := range edges {
+		ends := strings.SplitN(e, "->", 2)
+		defs = append(defs, model.XDCRDef{Rule: model.CustomRule, Source: model.Selector{"Bucket": ends[0]}, Destination: model.Selector{"Bucket": ends[1]}})
+	}
+	return &Blueprint{Datacenters: dcs, XDCRSets: []XDCRSet{{File: "XDCR.yaml", Definitions: defs, Datacenters: dcs}}}
+}
+
+// violations returns the violations written bucket: message.
+func violations(vs []Violation) []string {
+	result := []string{}
+	for _, v := range vs {
+		result = append(result, v.Bucket+": "+v.Message)
+	}
+	return result
+}
+
+func TestCheckPolicies(t *testing.T) {
+	buckets := []model.Bucket{
+		testBucket("DC1", "A", "Role", "resa", "Environment", "prod"),
+		testBucket("DC1", "B", "Role", "resa", "Environment", "test"),
+		testBucket("DC2", "C", "Role", "resa", "Environment", "prod"),
+		testBucket("DC2", "D", "Role", "stat", "Environment", "prod"),
+	}
+	buckets[0].CBReplicateNumber = 2
+
+	tests := []struct {
+		name   string
+		edges  []string
+		policy Policy
+		want   []string
+	}{
+		{
+			name:   "minInbound",
+			edges:  []string{"B->A", "C->A"},
+			policy: Policy{Buckets: model.Selector{"Bucket": "A"}, MinInbound: 2},
+			want:   []string{},
+		},
+		{
+			name:   "minInbound violated",
+			edges:  []string{"B->A"},
+			policy: Policy{Buckets: model.Selector{"Bucket": "A"}, MinInbound: 2},
+			want:   []string{"DC1___A: 1 inbound replication(s), expected at least 2"},
+		},
+		{
+			name:   "minInbound with inboundDifferentOn",
+			edges:  []string{"C->A", "D->A"},
+			policy: Policy{Buckets: model.Selector{"Bucket": "A"}, MinInbound: 2, InboundDifferentOn: []string{"Datacenter"}},
+			want:   []string{},
+		},
+		{
+			name:   "minInbound with inboundDifferentOn violated",
+			edges:  []string{"B->A", "C->A"},
+			policy: Policy{Buckets: model.Selector{"Bucket": "A"}, MinInbound: 2, InboundDifferentOn: []string{"Datacenter"}},
+			want:   []string{"DC1___A: 1 inbound replication(s) from a different [Datacenter], expected at least 2"},
+		},
+		{
+			name:   "maxFanOut",
+			edges:  []string{"A->B", "A->C"},
+			policy: Policy{MaxFanOut: 2},
+			want:   []string{},
+		},
+		{
+			name:   "maxFanOut violated",
+			edges:  []string{"A->B", "A->C", "A->D", "B->C"},
+			policy: Policy{MaxFanOut: 2},
+			want:   []string{"DC1___A: 3 outbound replications, expected at most 2"},
+		},
+		{
+			name:   "minReplicas",
+			policy: Policy{Buckets: model.Selector{"Bucket": "A"}, MinReplicas: 2},
+			want:   []string{},
+		},
+		{
+			name:   "minReplicas violated",
+			policy: Policy{Buckets: model.Selector{"Role": "resa"}, MinReplicas: 1},
+			want:   []string{"DC1___B: cbReplicaNumber is 0, expected at least 1", "DC2___C: cbReplicaNumber is 0, expected at least 1"},
+		},
+		{
+			name:   "forbid",
+			edges:  []string{"B->A"},
+			policy: Policy{ForbidSource: model.Selector{"Environment": "prod"}, ForbidDestination: model.Selector{"Environment": "test"}},
+			want:   []string{},
+		},
+		{
+			name:   "forbid violated",
+			edges:  []string{"A->B", "C->D"},
+			policy: Policy{ForbidSource: model.Selector{"Environment": "prod"}, ForbidDestination: model.Selector{"Environment": "test"}},
+			want:   []string{"DC1___A: forbidden replication to DC1___B"},
+		},
+		{
+			name:   "forbid bidirectional",
+			edges:  []string{"A->C"},
+			policy: Policy{ForbidSource: model.Selector{"Environment": "prod"}, ForbidDestination: model.Selector{"Environment": "test"}, Bidirectional: true},
+			want:   []string{},
+		},
+		{
+			name:   "forbid bidirectional violated",
+			edges:  []string{"B->A"},
+			policy: Policy{ForbidSource: model.Selector{"Environment": "prod"}, ForbidDestination: model.Selector{"Environment": "test"}, Bidirectional: true},
+			want:   []string{"DC1___B: forbidden replication to DC1___A"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := violations(CheckPolicies(testBlueprint(buckets, tt.edges...), []Policy{tt.policy})); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckPolicies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPoliciesDefinitions(t *testing.T) {
+	buckets := []model.Bucket{testBucket("DC1", "A"), testBucket("DC1", "B"), testBucket("DC1", "C")}
+	vs := CheckPolicies(testBlueprint(buckets, "A->B", "B->C", "A->C"), []Policy{{Name: "fan", MaxFanOut: 1}})
+	if len(vs) != 1 {
+		t.Fatalf("CheckPolicies() = %v, want one violation", vs)
+	}
+	want := []DefinitionRef{{File: "XDCR.yaml", Index: 0}, {File: "XDCR.yaml", Index: 2}}
+	if vs[0].Policy != "fan" || !reflect.DeepEqual(vs[0].Definitions, want) {
+		t.Errorf("CheckPolicies() = %v, want the policy fan and the definitions %v", vs[0], want)
+	}
+}
@@ -0,0 +1,220 @@
+package expansion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// FileError is the error of a file that can not be decoded, with the position of each of its problems.
+type FileError struct {
+	File     string
+	Problems []Problem
+}
+
+func (e *FileError) Error() string {
+	messages := []string{}
+	for _, p := range e.Problems {
+		messages = append(messages, p.Location()+": "+p.Message)
+	}
+	return strings.Join(messages, "\n")
+}
+
+// locator finds the position of the keys and items of a yaml or json file.
+type locator struct {
+	root  *yaml3.Node
+	lines []string
+}
+
+// newLocator returns nil when the file can not be read, the problems of files that can not be parsed are reported
+// without position.
+func newLocator(file string) *locator {
+	format, b, err := readFile(file)
+	if err != nil {
+		return nil
+	}
+	l := &locator{lines: strings.Split(string(b), "\n")}
+	var root yaml3.Node
+	// toml and hcl files are not positioned
+	if (format == YAMLFormat || format == JSONFormat) && yaml3.Unmarshal(b, &root) == nil && len(root.Content) > 0 {
+		l.root = root.Content[0]
+	}
+	return l
+}
+
+// find returns the line and column of the element at path, made of map keys and sequence indexes. When the path
+// does not exist the position of its deepest existing element is returned.
+func (l *locator) find(path ...interface{}) (int, int) {
+	if l == nil || l.root == nil {
+		return 0, 0
+	}
+	n := l.root
+	line, column := n.Line, n.Column
+	for _, p := range path {
+		var next, at *yaml3.Node
+		switch p := p.(type) {
+		case string:
+			if n.Kind != yaml3.MappingNode {
+				return line, column
+			}
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				// json keys are matched case insensitively, as encoding/json does
+				if strings.EqualFold(n.Content[i].Value, p) {
+					at, next = n.Content[i], n.Content[i+1]
+					break
+				}
+			}
+		case int:
+			if n.Kind == yaml3.SequenceNode && p >= 0 && p < len(n.Content) {
+				at, next = n.Content[p], n.Content[p]
+			}
+		}
+		if next == nil {
+			return line, column
+		}
+		n, line, column = next, at.Line, at.Column
+	}
+	return line, column
+}
+
+func (l *locator) snippet(line int) string {
+	if l == nil || line < 1 || line > len(l.lines) {
+		return ""
+	}
+	return strings.TrimRight(l.lines[line-1], "\r")
+}
+
+// problem returns a problem positioned on the element at path.
+func (l *locator) problem(file string, index int, warning bool, message string, path ...interface{}) Problem {
+	p := Problem{File: file, Index: index, Message: message, Warning: warning}
+	p.Line, p.Column = l.find(path...)
+	p.Snippet = l.snippet(p.Line)
+	return p
+}
+
+var yamlLineError = regexp.MustCompile(`line (\d+): (.*)`)
+
+// decodeError converts a yaml or json decoding error into positioned problems.
+func decodeError(file string, b []byte, err error) error {
+	l := &locator{lines: strings.Split(string(b), "\n")}
+	fe := &FileError{File: file}
+	add := func(line, column int, message string) {
+		if line > 0 && column == 0 {
+			// yaml.v2 gives no column, point at the first character of the line
+			s := l.snippet(line)
+			column = len(s) - len(strings.TrimLeft(s, " \t")) + 1
+		}
+		fe.Problems = append(fe.Problems, Problem{File: file, Index: -1, Line: line, Column: column, Message: message, Snippet: l.snippet(line)})
+	}
+	offset := func(o int64) (int, int) {
+		if o > int64(len(b)) {
+			o = int64(len(b))
+		}
+		before := b[:o]
+		line := bytes.Count(before, []byte("\n")) + 1
+		return line, int(o) - bytes.LastIndexByte(before, '\n')
+	}
+
+	switch e := err.(type) {
+	case *yaml.TypeError:
+		for _, m := range e.Errors {
+			if s := yamlLineError.FindStringSubmatch(m); s != nil {
+				line, _ := strconv.Atoi(s[1])
+				add(line, 0, s[2])
+			} else {
+				add(0, 0, m)
+			}
+		}
+	case *json.SyntaxError:
+		line, column := offset(e.Offset)
+		add(line, column, e.Error())
+	case *json.UnmarshalTypeError:
+		line, column := offset(e.Offset)
+		add(line, column, fmt.Sprintf("cannot unmarshal %s into %s of type %s", e.Value, e.Field, e.Type))
+	default:
+		if s := yamlLineError.FindStringSubmatch(err.Error()); s != nil {
+			line, _ := strconv.Atoi(s[1])
+			add(line, 0, s[2])
+		} else if strings.HasPrefix(err.Error(), "json: unknown field ") {
+			// the decoder does not give the offset of unknown fields, look for the key
+			key, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+			line, column := 0, 0
+			if i := bytes.Index(b, []byte(strconv.Quote(key))); i >= 0 {
+				line, column = offset(int64(i))
+			}
+			add(line, column, err.Error())
+		} else {
+			add(0, 0, err.Error())
+		}
+	}
+	return fe
+}
+
+// selectorProblems returns the positioned problems of the malformed selector strings of a yaml or json file decoded
+// into a value of type t, the decoders report them without position.
+func selectorProblems(file string, l *locator, t reflect.Type) []Problem {
+	if l == nil || l.root == nil {
+		return nil
+	}
+	problems := []Problem{}
+	var walk func(n *yaml3.Node, t reflect.Type)
+	walk = func(n *yaml3.Node, t reflect.Type) {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == reflect.TypeOf(model.Selector{}) {
+			if n.Kind == yaml3.ScalarNode {
+				if _, err := model.ParseSelector(n.Value); err != nil {
+					problems = append(problems, Problem{File: file, Index: -1, Line: n.Line, Column: n.Column, Message: err.Error(), Snippet: l.snippet(n.Line)})
+				}
+			}
+			return
+		}
+		switch t.Kind() {
+		case reflect.Slice, reflect.Array:
+			if n.Kind == yaml3.SequenceNode {
+				for _, item := range n.Content {
+					walk(item, t.Elem())
+				}
+			}
+		case reflect.Map:
+			if n.Kind == yaml3.MappingNode {
+				for i := 1; i < len(n.Content); i += 2 {
+					walk(n.Content[i], t.Elem())
+				}
+			}
+		case reflect.Struct:
+			if n.Kind != yaml3.MappingNode {
+				return
+			}
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				if f.Anonymous && strings.Contains(f.Tag.Get("yaml"), "inline") {
+					walk(n, f.Type)
+					continue
+				}
+				key, ok := yamlKey(f)
+				if !ok {
+					continue
+				}
+				// the json decoder matches the keys regardless of their case
+				jsonKey := strings.Split(f.Tag.Get("json"), ",")[0]
+				for j := 0; j+1 < len(n.Content); j += 2 {
+					if k := n.Content[j].Value; strings.EqualFold(k, key) || (jsonKey != "" && strings.EqualFold(k, jsonKey)) {
+						walk(n.Content[j+1], f.Type)
+					}
+				}
+			}
+		}
+	}
+	walk(l.root, t)
+	return problems
+}
@@ -0,0 +1,87 @@
+package expansion
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// BucketQueryResult is a bucket of the expanded model matching a query, with its replication counts.
+type BucketQueryResult struct {
+	Path              string       `json:"path"`
+	Name              string       `json:"name"`
+	RamQuota          int          `json:"ramQuota"`
+	CBReplicateNumber int          `json:"cbReplicaNumber"`
+	Labels            model.Labels `json:"labels"`
+	Inbound           int          `json:"inbound"`
+	Outbound          int          `json:"outbound"`
+}
+
+// QueryBuckets returns the buckets of the blueprint matching the selector, an empty selector matches all the buckets.
+func QueryBuckets(bp *Blueprint, s model.Selector) []BucketQueryResult {
+	inbound := map[string]int{}
+	outbound := map[string]int{}
+	for _, x := range bp.XDCRs() {
+		outbound[x.Source.Path()]++
+		inbound[x.Destination.Path()]++
+	}
+
+	buckets := model.BucketByPath{}
+	for _, dc := range bp.Datacenters {
+		for _, b := range dc.GetBuckets() {
+			if len(s) == 0 || b.Match(s) {
+				buckets = append(buckets, b)
+			}
+		}
+	}
+	sort.Sort(buckets)
+
+	results := []BucketQueryResult{}
+	for _, b := range buckets {
+		p := b.Path()
+		results = append(results, BucketQueryResult{
+			Path:              p,
+			Name:              b.Name,
+			RamQuota:          b.RamQuota,
+			CBReplicateNumber: b.CBReplicateNumber,
+			Labels:            b.Labels,
+			Inbound:           inbound[p],
+			Outbound:          outbound[p],
+		})
+	}
+	return results
+}
+
+func WriteQueryResults(w io.Writer, format string, results []BucketQueryResult) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "PATH\tNAME\tRAMQUOTA\tREPLICAS\tIN\tOUT\tLABELS\n")
+		for _, r := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n", r.Path, r.Name, r.RamQuota, r.CBReplicateNumber, r.Inbound, r.Outbound, r.Labels.String())
+		}
+		return tw.Flush()
+	case "json":
+		b, err := json.MarshalIndent(results, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"path", "name", "ramQuota", "cbReplicaNumber", "inbound", "outbound", "labels"})
+		for _, r := range results {
+			cw.Write([]string{r.Path, r.Name, strconv.Itoa(r.RamQuota), strconv.Itoa(r.CBReplicateNumber), strconv.Itoa(r.Inbound), strconv.Itoa(r.Outbound), r.Labels.String()})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("Unknown query output %q, expected table, json or csv", format)
+}
@@ -0,0 +1,131 @@
+package expansion
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+	"github.com/dbenque/couchbaseblueprint/rules"
+)
+
+// SchemaTypes are the input files a JSON Schema can be generated for.
+var SchemaTypes = map[string]interface{}{
+	"topology":    model.ClusterGroupDefBluePrint{},
+	"xdcr":        model.XDCRDefBluePrint{},
+	"dc":          DCInjector{},
+	"datacenters": model.DatacenterDefBluePrint{},
+	"policy":      PolicyBluePrint{},
+	"style":       model.StyleBluePrint{},
+}
+
+// SchemaNames returns the sorted names of the SchemaTypes.
+func SchemaNames() []string {
+	names := []string{}
+	for n := range SchemaTypes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Schema returns the JSON Schema of one of the SchemaTypes. The property names are the keys of the yaml files, the
+// unknown properties are rejected as they are by the strict decoding of the files.
+func Schema(name string) (map[string]interface{}, error) {
+	v, ok := SchemaTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown schema %q, expected one of %v", name, SchemaNames())
+	}
+	s := typeSchema(reflect.TypeOf(v))
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = name
+	if properties, ok := s["properties"].(map[string]interface{}); ok {
+		properties["apiVersion"] = map[string]interface{}{"type": "string", "enum": []string{LatestAPIVersion}}
+		properties["kind"] = map[string]interface{}{"type": "string", "enum": []string{fileKind(reflect.New(reflect.TypeOf(v)).Interface())}}
+	}
+	return s, nil
+}
+
+// schemaEnums lists the accepted values of the string types used as enums.
+func schemaEnums(t reflect.Type) []string {
+	switch t {
+	case reflect.TypeOf(model.XDCRRule("")):
+		names := []string{}
+		for _, n := range rules.Names() {
+			names = append(names, string(n))
+		}
+		return names
+	case reflect.TypeOf(model.FanOut("")):
+		return []string{string(model.RoundRobinFanOut), string(model.BalancedFanOut), string(model.AffinityFanOut)}
+	case reflect.TypeOf(model.Pairing("")):
+		return []string{string(model.CartesianPairing), string(model.ZipPairing), string(model.MatchPairing)}
+	case reflect.TypeOf(model.ConflictResolution("")):
+		return []string{string(model.SeqnoConflictResolution), string(model.LWWConflictResolution)}
+	case reflect.TypeOf(model.BoxKind("")):
+		return []string{string(model.DatacenterBox), string(model.ClusterGroupBox), string(model.ClusterBox)}
+	}
+	return nil
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(model.Selector{}) {
+		return map[string]interface{}{"oneOf": []interface{}{
+			map[string]interface{}{"type": "string", "pattern": "^([^,=]+=[^,]*)(,[^,=]+=[^,]*)*$"},
+			map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+		}}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		s := map[string]interface{}{"type": "string"}
+		if enum := schemaEnums(t); enum != nil {
+			s["enum"] = enum
+		}
+		return s
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous && strings.Contains(f.Tag.Get("yaml"), "inline") {
+				for k, v := range typeSchema(f.Type)["properties"].(map[string]interface{}) {
+					properties[k] = v
+				}
+				continue
+			}
+			name, ok := yamlKey(f)
+			if !ok {
+				continue
+			}
+			properties[name] = typeSchema(f.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+	}
+	return map[string]interface{}{}
+}
+
+// yamlKey returns the key of a struct field in yaml files, following the rules of yaml.v2.
+func yamlKey(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	tag := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	switch tag {
+	case "-":
+		return "", false
+	case "":
+		return strings.ToLower(f.Name), true
+	}
+	return tag, true
+}
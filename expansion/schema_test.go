@@ -0,0 +1,171 @@
+package expansion
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+	"gopkg.in/yaml.v2"
+)
+
+// fill sets every field of the value to a value that is not the zero one, the slices and maps having one element.
+func fill(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem())
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		key, value := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+		fill(key)
+		fill(value)
+		v.SetMapIndex(key, value)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				fill(v.Field(i))
+			}
+		}
+	}
+}
+
+// keyPaths returns the sorted paths of the keys of the objects of a decoded document, the items of the arrays being
+// written []. The maps of free keys, as the labels and the selectors, are not walked.
+func keyPaths(doc interface{}, prefix string, paths map[string]bool) {
+	switch d := doc.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range d {
+			path := prefix + "." + k.(string)
+			paths[path] = true
+			keyPaths(v, path, paths)
+		}
+	case []interface{}:
+		for _, v := range d {
+			keyPaths(v, prefix+"[]", paths)
+		}
+	}
+}
+
+// schemaPaths returns the paths of the properties of a schema, written as the ones of keyPaths.
+func schemaPaths(s map[string]interface{}, prefix string, paths map[string]bool) {
+	if properties, ok := s["properties"].(map[string]interface{}); ok {
+		for k, v := range properties {
+			path := prefix + "." + k
+			paths[path] = true
+			schemaPaths(v.(map[string]interface{}), path, paths)
+		}
+	}
+	if items, ok := s["items"].(map[string]interface{}); ok {
+		schemaPaths(items, prefix+"[]", paths)
+	}
+}
+
+func sortedPaths(paths map[string]bool) []string {
+	result := []string{}
+	for p := range paths {
+		result = append(result, p)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// TestSchemaFields checks that the schemas list the keys of the files of their types, which are decoded strictly.
+func TestSchemaFields(t *testing.T) {
+	for _, name := range SchemaNames() {
+		t.Run(name, func(t *testing.T) {
+			s, err := Schema(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v := reflect.New(reflect.TypeOf(SchemaTypes[name]))
+			fill(v.Elem())
+			v.Elem().FieldByName("Header").Set(reflect.ValueOf(NewHeader(fileKind(v.Interface()))))
+			b, err := yaml.Marshal(v.Interface())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the keys written for the structs are accepted when they are read back
+			file := writeTestFile(t, "file.yaml", string(b))
+			if err := unmarshalFile(file, reflect.New(v.Elem().Type()).Interface()); err != nil {
+				t.Errorf("unmarshalFile() error = %v", err)
+			}
+
+			var doc interface{}
+			if err := yaml.Unmarshal(b, &doc); err != nil {
+				t.Fatal(err)
+			}
+			keys, properties := map[string]bool{}, map[string]bool{}
+			keyPaths(doc, "", keys)
+			schemaPaths(s, "", properties)
+			// the labels and selectors keys are free
+			for k := range keys {
+				if strings.HasSuffix(k, ".x") {
+					delete(keys, k)
+				}
+			}
+			if got, want := sortedPaths(properties), sortedPaths(keys); !reflect.DeepEqual(got, want) {
+				t.Errorf("Schema(%q) properties = %v, want %v", name, got, want)
+			}
+		})
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		v       interface{}
+		line    int
+		column  int
+		message string
+	}{
+		{
+			name:    "yaml",
+			file:    "XDCR.yaml",
+			content: "apiVersion: couchbaseblueprint/v2\nkind: XDCR\nxdcrdefs:\n- rule: ring\n  sourse: Role=Resa\n",
+			v:       &model.XDCRDefBluePrint{},
+			line:    5, column: 3, message: "sourse",
+		},
+		{
+			name:    "yaml nested",
+			file:    "couchbase.yaml",
+			content: "apiVersion: couchbaseblueprint/v2\nkind: Topology\nclustergroups:\n- name: CG\n  peakTokens: [LH]\n  clusters:\n  - name: C\n    buckets:\n    - name: B\n      ramquotas: 256\n",
+			v:       &model.ClusterGroupDefBluePrint{},
+			line:    10, column: 7, message: "ramquotas",
+		},
+		{
+			name:    "json",
+			file:    "XDCR.json",
+			content: "{\n  \"apiVersion\": \"couchbaseblueprint/v2\",\n  \"kind\": \"XDCR\",\n  \"xdcrdefs\": [\n    {\"rule\": \"ring\",\n     \"sourse\": \"Role=Resa\"}\n  ]\n}\n",
+			v:       &model.XDCRDefBluePrint{},
+			line:    6, column: 6, message: "sourse",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := unmarshalFile(writeTestFile(t, tt.file, tt.content), tt.v)
+			fe, ok := err.(*FileError)
+			if !ok || len(fe.Problems) != 1 {
+				t.Fatalf("unmarshalFile() error = %v, want one problem", err)
+			}
+			p := fe.Problems[0]
+			if p.Line != tt.line || p.Column != tt.column || !strings.Contains(p.Message, tt.message) {
+				t.Errorf("unmarshalFile() problem = %d:%d %s, want %d:%d %s", p.Line, p.Column, p.Message, tt.line, tt.column, tt.message)
+			}
+		})
+	}
+}
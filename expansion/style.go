@@ -0,0 +1,43 @@
+package expansion
+
+import (
+	"fmt"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// StyleFromFile reads the style of the graphs from a file.
+//
+//	nodes:
+//	- selector: Level=1
+//	  shape: box
+//	  fill: lightblue
+//	clusters:
+//	- box: datacenter
+//	  selector: Datacenter=dc1
+//	  style: dashed
+//	edges:
+//	  bidirectional: dashed
+//	  priorityWidth: 0.5
+func StyleFromFile(file string) (*model.StyleBluePrint, error) {
+	var style model.StyleBluePrint
+	if err := unmarshalFile(file, &style); err != nil {
+		return nil, err
+	}
+	l := newLocator(file)
+	fe := &FileError{File: file}
+	for i, c := range style.Clusters {
+		switch c.Box {
+		case model.DatacenterBox, model.ClusterGroupBox, model.ClusterBox:
+		default:
+			fe.Problems = append(fe.Problems, l.problem(file, i, false, fmt.Sprintf("invalid box %q, expected %s, %s or %s", c.Box, model.DatacenterBox, model.ClusterGroupBox, model.ClusterBox), "clusters", i, "box"))
+		}
+	}
+	if style.Edges.PriorityWidth < 0 {
+		fe.Problems = append(fe.Problems, l.problem(file, -1, false, "priorityWidth must not be negative", "edges", "priorityWidth"))
+	}
+	if len(fe.Problems) > 0 {
+		return nil, fe
+	}
+	return &style, nil
+}
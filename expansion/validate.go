@@ -0,0 +1,153 @@
+package expansion
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+	"github.com/dbenque/couchbaseblueprint/rules"
+)
+
+// Problem is an error or a warning found while validating a blueprint. Line and Column are 0 when the position of
+// the problem in the file is unknown. The problems of the expanded blueprint that no file holds, as the ones of a
+// datacenter, have a Subject instead of a File.
+type Problem struct {
+	File    string `json:"file"`
+	Subject string `json:"subject,omitempty"`
+	Index   int    `json:"index"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning"`
+}
+
+// Location returns file:line:column, or the subject of a problem without file, followed by the index of the
+// definition if any.
+func (p Problem) Location() string {
+	if p.File == "" && p.Subject != "" {
+		return p.Subject
+	}
+	l := p.File
+	if p.Line > 0 {
+		l += fmt.Sprintf(":%d:%d", p.Line, p.Column)
+	}
+	if p.Index >= 0 {
+		l += fmt.Sprintf(" #%d", p.Index)
+	}
+	return l
+}
+
+func (p Problem) String() string {
+	level := "error"
+	if p.Warning {
+		level = "warning"
+	}
+	return fmt.Sprintf("%s: %s: %s", level, p.Location(), p.Message)
+}
+
+// Context returns the offending line followed by a caret under the column, or an empty string when unknown.
+func (p Problem) Context() string {
+	if p.Snippet == "" {
+		return ""
+	}
+	column := p.Column
+	if column < 1 {
+		column = 1
+	}
+	return "    " + p.Snippet + "\n    " + strings.Repeat(" ", column-1) + "^"
+}
+
+// Validate checks the datacenters and the XDCR definitions of an expanded blueprint.
+func Validate(bp *Blueprint) []Problem {
+	problems := []Problem{}
+	for _, dc := range bp.Datacenters {
+		if len(dc.GetBuckets()) == 0 {
+			problems = append(problems, Problem{Subject: "datacenter " + dc.Name, Index: -1, Message: "datacenter has no bucket", Warning: true})
+		}
+		for _, b := range dc.GetBuckets() {
+			switch b.Resolution() {
+			case model.SeqnoConflictResolution, model.LWWConflictResolution:
+			default:
+				problems = append(problems, Problem{Subject: "datacenter " + dc.Name, Index: -1, Message: fmt.Sprintf("bucket %s has unknown conflictResolution %q, expected seqno or lww", b.Path(), b.ConflictResolution)})
+			}
+		}
+		for _, o := range dc.Overrides {
+			found := false
+			for _, cg := range dc.ClusterGroups {
+				found = found || cg.Name == o.Name
+			}
+			if !found {
+				problems = append(problems, Problem{Subject: "datacenter " + dc.Name, Index: -1, Message: fmt.Sprintf("override of unknown cluster group %s", o.Name), Warning: true})
+			}
+		}
+	}
+
+	for _, set := range bp.XDCRSets {
+		l := newLocator(set.File)
+		replications := set.Replications()
+		for i, def := range set.Definitions {
+			// key is the field of the definition the problem is positioned on
+			report := func(key string, warning bool, format string, a ...interface{}) {
+				problems = append(problems, l.problem(set.File, i, warning, fmt.Sprintf(format, a...), "xdcrdefs", i, key))
+			}
+			if !rules.Implemented(def.Rule) {
+				report("rule", false, "unknown rule %q, expected one of %v", def.Rule, rules.Names())
+				continue
+			}
+			// a missing selector matches no bucket, an empty one matches them all
+			if def.Source == nil {
+				report("source", false, "source selector is missing, it matches no bucket")
+				continue
+			}
+			if len(def.Source) == 0 {
+				report("source", true, "source selector is empty, it matches every bucket")
+			}
+			if def.Rule == model.CustomRule && def.Destination == nil {
+				report("destination", false, "destination selector is missing, it matches no bucket")
+				continue
+			}
+			if def.Rule == model.CustomRule && len(def.Destination) == 0 {
+				report("destination", true, "destination selector is empty, it matches every bucket")
+			}
+			if def.Rule == model.StarRule && def.HubCount < 0 {
+				report("hubCount", false, "hubCount must not be negative")
+				continue
+			}
+			if def.Rule == model.MeshRule && def.MaxPeers < 0 {
+				report("maxPeers", false, "maxPeers must not be negative")
+				continue
+			}
+			isTree := def.Rule == model.TreeRule || def.Rule == model.UptreeRule
+			switch def.FanOut {
+			case "", model.RoundRobinFanOut, model.BalancedFanOut, model.AffinityFanOut:
+			default:
+				report("fanOut", false, "unknown fanOut %q, expected one of %v", def.FanOut, []model.FanOut{model.RoundRobinFanOut, model.BalancedFanOut, model.AffinityFanOut})
+				continue
+			}
+			switch def.Pairing {
+			case "", model.CartesianPairing, model.ZipPairing, model.MatchPairing:
+			default:
+				report("pairing", false, "unknown pairing %q, expected one of %v", def.Pairing, []model.Pairing{model.CartesianPairing, model.ZipPairing, model.MatchPairing})
+				continue
+			}
+			if def.Pairing == model.MatchPairing && len(def.PairOn) == 0 {
+				report("pairing", false, "match pairing needs pairOn labels")
+				continue
+			}
+			if def.Rule != model.CustomRule && (def.Pairing != "" || len(def.PairOn) > 0) {
+				report("pairing", true, "pairing and pairOn are ignored by rule %q", def.Rule)
+			}
+			if !isTree && (len(def.LevelOn) > 0 || def.FanOut != "" || len(def.Affinity) > 0) {
+				report("rule", true, "levelOn, fanOut and affinity are ignored by rule %q", def.Rule)
+			}
+			if !rules.UsesDestination(def) && def.Rule != model.StarRule && (def.Destination != nil || def.DestinationExclude != nil) {
+				report("destination", true, "destination selectors are ignored by rule %q", def.Rule)
+			}
+			if len(replications[i]) == 0 {
+				report("rule", true, "definition produces no replication")
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,83 @@
+package expansion
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func TestValidateSelectors(t *testing.T) {
+	buckets := []model.Bucket{testBucket("DC1", "A"), testBucket("DC1", "B")}
+	tests := []struct {
+		name string
+		def  model.XDCRDef
+		want []string
+	}{
+		{"missing source", model.XDCRDef{Rule: model.RingRule}, []string{"error: source selector is missing, it matches no bucket"}},
+		{"empty source", model.XDCRDef{Rule: model.RingRule, Source: model.Selector{}}, []string{"warning: source selector is empty, it matches every bucket"}},
+		{"missing destination", model.XDCRDef{Rule: model.CustomRule, Source: model.Selector{"Bucket": "A"}}, []string{"error: destination selector is missing, it matches no bucket"}},
+		{"empty destination", model.XDCRDef{Rule: model.CustomRule, Source: model.Selector{"Bucket": "A"}, Destination: model.Selector{}},
+			[]string{"warning: destination selector is empty, it matches every bucket"}},
+		{"selectors", model.XDCRDef{Rule: model.CustomRule, Source: model.Selector{"Bucket": "A"}, Destination: model.Selector{"Bucket": "B"}}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bp := testBlueprint(buckets)
+			bp.XDCRSets[0].Definitions = []model.XDCRDef{tt.def}
+			got := []string{}
+			for _, p := range Validate(bp) {
+				level := "error"
+				if p.Warning {
+					level = "warning"
+				}
+				got = append(got, level+": "+p.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDatacenters(t *testing.T) {
+	bp := testBlueprint([]model.Bucket{testBucket("DC1", "A")})
+	bp.Datacenters = append(bp.Datacenters, model.Datacenter{Name: "DC2"})
+	problems := Validate(bp)
+	if len(problems) != 1 {
+		t.Fatalf("Validate() = %v, want one problem", problems)
+	}
+	p := problems[0]
+	if p.File != "" || p.Subject != "datacenter DC2" {
+		t.Errorf("Validate() problem of file %q and subject %q, want the subject datacenter DC2 without file", p.File, p.Subject)
+	}
+	if want := "warning: datacenter DC2: datacenter has no bucket"; p.String() != want {
+		t.Errorf("Validate() problem = %q, want %q", p.String(), want)
+	}
+}
+
+func TestValidateConflictResolution(t *testing.T) {
+	tests := []struct {
+		resolution model.ConflictResolution
+		want       []string
+	}{
+		{"", []string{}},
+		{"seqno", []string{}},
+		{"LWW", []string{}},
+		{"SeqNo", []string{}},
+		{"custom", []string{`bucket DC1___A has unknown conflictResolution "custom", expected seqno or lww`}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.resolution), func(t *testing.T) {
+			b := testBucket("DC1", "A")
+			b.ConflictResolution = tt.resolution
+			got := []string{}
+			for _, p := range Validate(testBlueprint([]model.Bucket{b})) {
+				got = append(got, p.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
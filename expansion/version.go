@@ -0,0 +1,370 @@
+package expansion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// API versions of the blueprint files. The v1 files have no header, v2 renamed peakToken to peakTokens and
+// cbReplicatNumber to cbReplicaNumber, and accepts selectors written as "k=v,k2=v2" strings.
+const (
+	APIVersionV1     = "couchbaseblueprint/v1"
+	APIVersionV2     = "couchbaseblueprint/v2"
+	LatestAPIVersion = APIVersionV2
+)
+
+// Kinds of blueprint files.
+const (
+	TopologyKind    = "Topology"
+	XDCRKind        = "XDCR"
+	DCMappingKind   = "DCMapping"
+	DatacentersKind = "Datacenters"
+	PolicyKind      = "Policy"
+	StyleKind       = "Style"
+)
+
+// NewHeader returns the header of a file of the latest API version.
+func NewHeader(kind string) model.Header {
+	return model.Header{APIVersion: LatestAPIVersion, Kind: kind}
+}
+
+// migration upgrades the document of a file from one API version to the next one.
+type migration struct {
+	From    string
+	To      string
+	Upgrade func(kind string, doc *yaml3.Node)
+}
+
+var migrations = []migration{
+	{From: APIVersionV1, To: APIVersionV2, Upgrade: upgradeV1},
+}
+
+// fileKind returns the kind of the file decoded into v.
+func fileKind(v interface{}) string {
+	switch v.(type) {
+	case *model.ClusterGroupDefBluePrint:
+		return TopologyKind
+	case *model.XDCRDefBluePrint:
+		return XDCRKind
+	case *DCInjector:
+		return DCMappingKind
+	case *model.DatacenterDefBluePrint:
+		return DatacentersKind
+	case *PolicyBluePrint:
+		return PolicyKind
+	case *model.StyleBluePrint:
+		return StyleKind
+	}
+	return ""
+}
+
+// guessKind finds the kind of a file without header from its top level keys.
+func guessKind(doc *yaml3.Node) string {
+	switch {
+	case mapValue(doc, "topos") != nil || mapValue(doc, "xdcrs") != nil:
+		return DCMappingKind
+	case mapValue(doc, "clustergroups") != nil:
+		return TopologyKind
+	case mapValue(doc, "xdcrdefs") != nil:
+		return XDCRKind
+	case mapValue(doc, "policies") != nil:
+		return PolicyKind
+	case mapValue(doc, "datacenters") != nil:
+		return DatacentersKind
+	case mapValue(doc, "nodes") != nil || mapValue(doc, "clusters") != nil || mapValue(doc, "edges") != nil:
+		return StyleKind
+	}
+	return ""
+}
+
+// readHeader returns the API version and the kind of the document, v1 and the guessed kind for a file without
+// header.
+func readHeader(doc *yaml3.Node) (string, string) {
+	version, kind := APIVersionV1, guessKind(doc)
+	if n := mapValue(doc, "apiVersion"); n != nil {
+		version = n.Value
+	}
+	if n := mapValue(doc, "kind"); n != nil {
+		kind = n.Value
+	}
+	return version, kind
+}
+
+// migrateDocument upgrades the document to the latest API version and sets its header. It returns false when the
+// document already is of the latest version.
+func migrateDocument(file string, l *locator, expectedKind string) (bool, error) {
+	doc := l.root
+	version, kind := readHeader(doc)
+	if expectedKind != "" && kind != expectedKind {
+		return false, &FileError{File: file, Problems: []Problem{l.problem(file, -1, false, fmt.Sprintf("kind is %q, expected %q", kind, expectedKind), "kind")}}
+	}
+	if version == LatestAPIVersion {
+		return false, nil
+	}
+
+	for _, m := range migrations {
+		if m.From == version {
+			m.Upgrade(kind, doc)
+			version = m.To
+		}
+	}
+	if version != LatestAPIVersion {
+		known := []string{LatestAPIVersion}
+		for _, m := range migrations {
+			known = append(known, m.From)
+		}
+		sort.Strings(known)
+		return false, &FileError{File: file, Problems: []Problem{l.problem(file, -1, false, fmt.Sprintf("unknown apiVersion %q, expected one of %v", version, known), "apiVersion")}}
+	}
+
+	setMapValue(doc, "kind", kind)
+	setMapValue(doc, "apiVersion", LatestAPIVersion)
+	return true, nil
+}
+
+// upgradeFile returns the content of a file of an older API version upgraded to the latest one, b itself when it is
+// already of the latest version. The origin of the lines of an upgraded content is returned with it, nil otherwise.
+func upgradeFile(file, format string, b []byte, kind string) ([]byte, lineOrigin, error) {
+	var root yaml3.Node
+	if err := yaml3.Unmarshal(b, &root); err != nil || len(root.Content) == 0 || root.Content[0].Kind != yaml3.MappingNode {
+		// the strict decoding reports the problem
+		return b, nil, nil
+	}
+	l := &locator{root: root.Content[0], lines: strings.Split(string(b), "\n")}
+	migrated, err := migrateDocument(file, l, kind)
+	if err != nil || !migrated {
+		return b, nil, err
+	}
+	upgraded, err := encodeDocument(&root, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	origin := lineOrigin{}
+	var encoded yaml3.Node
+	if yaml3.Unmarshal(upgraded, &encoded) == nil && len(encoded.Content) > 0 {
+		origin.add(encoded.Content[0], root.Content[0])
+	}
+	return upgraded, origin, nil
+}
+
+// lineOrigin maps the lines of an upgraded content to the line and column of their first element in the original
+// file, so that the decoding problems of an upgraded file are reported where the user wrote them.
+type lineOrigin map[int][2]int
+
+// add maps the lines of the encoded node and of its children to the ones of the migrated node they were encoded
+// from. The migrated nodes keep the position they were parsed at, the header they are given has none.
+func (o lineOrigin) add(encoded, migrated *yaml3.Node) {
+	if _, ok := o[encoded.Line]; !ok && migrated.Line > 0 {
+		o[encoded.Line] = [2]int{migrated.Line, migrated.Column}
+	}
+	switch encoded.Kind {
+	case yaml3.MappingNode:
+		// the json encoding sorts the keys, they are paired by name
+		for i := 0; i+1 < len(encoded.Content); i += 2 {
+			if migrated.Kind != yaml3.MappingNode {
+				return
+			}
+			for j := 0; j+1 < len(migrated.Content); j += 2 {
+				if migrated.Content[j].Value == encoded.Content[i].Value {
+					o.add(encoded.Content[i], migrated.Content[j])
+					o.add(encoded.Content[i+1], migrated.Content[j+1])
+					break
+				}
+			}
+		}
+	case yaml3.SequenceNode:
+		for i := range encoded.Content {
+			if migrated.Kind == yaml3.SequenceNode && i < len(migrated.Content) {
+				o.add(encoded.Content[i], migrated.Content[i])
+			}
+		}
+	}
+}
+
+// restore moves the problems found in the upgraded content to their position in the original file, the problems of
+// lines without origin lose their position.
+func (o lineOrigin) restore(fe *FileError, original []byte) {
+	l := &locator{lines: strings.Split(string(original), "\n")}
+	for i := range fe.Problems {
+		p := &fe.Problems[i]
+		if p.Line == 0 {
+			continue
+		}
+		if at, ok := o[p.Line]; ok {
+			p.Line, p.Column = at[0], at[1]
+			p.Snippet = l.snippet(p.Line)
+		} else {
+			p.Line, p.Column, p.Snippet = 0, 0, ""
+		}
+	}
+}
+
+func encodeDocument(root *yaml3.Node, format string) ([]byte, error) {
+	if format == JSONFormat {
+		var v interface{}
+		if err := root.Decode(&v); err != nil {
+			return nil, err
+		}
+		b, err := json.MarshalIndent(v, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	}
+	var buf bytes.Buffer
+	e := yaml3.NewEncoder(&buf)
+	e.SetIndent(2)
+	if err := e.Encode(root); err != nil {
+		return nil, err
+	}
+	e.Close()
+	return buf.Bytes(), nil
+}
+
+// MigrateFile returns the content of the file rewritten for the latest API version. The comments of yaml files are
+// kept.
+func MigrateFile(file string) ([]byte, error) {
+	format, b, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if format != YAMLFormat && format != JSONFormat {
+		return nil, fmt.Errorf("%s: can not migrate %s files, only %s and %s", file, format, YAMLFormat, JSONFormat)
+	}
+	var root yaml3.Node
+	if err := yaml3.Unmarshal(b, &root); err != nil {
+		return nil, decodeError(file, b, err)
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml3.MappingNode {
+		return nil, fmt.Errorf("%s: not a blueprint file", file)
+	}
+	l := &locator{root: root.Content[0], lines: strings.Split(string(b), "\n")}
+	if _, err := migrateDocument(file, l, ""); err != nil {
+		return nil, err
+	}
+	if _, kind := readHeader(root.Content[0]); kind == "" {
+		return nil, fmt.Errorf("%s: unknown kind of file", file)
+	}
+	// the header goes first
+	doc := root.Content[0]
+	for _, key := range []string{"kind", "apiVersion"} {
+		for i := 0; i+1 < len(doc.Content); i += 2 {
+			if doc.Content[i].Value == key {
+				pair := []*yaml3.Node{doc.Content[i], doc.Content[i+1]}
+				doc.Content = append(pair, append(doc.Content[:i:i], doc.Content[i+2:]...)...)
+				break
+			}
+		}
+	}
+	return encodeDocument(&root, format)
+}
+
+func upgradeV1(kind string, doc *yaml3.Node) {
+	buckets := func(clusters *yaml3.Node) {
+		for _, c := range seqItems(clusters) {
+			for _, b := range seqItems(mapValue(c, "buckets")) {
+				renameKey(b, "cbReplicatNumber", "cbReplicaNumber")
+			}
+		}
+	}
+	clusterGroups := func(cgs *yaml3.Node) {
+		for _, cg := range seqItems(cgs) {
+			renameKey(cg, "peakToken", "peakTokens")
+			buckets(mapValue(cg, "clusters"))
+		}
+	}
+	datacenters := func(dcs *yaml3.Node) {
+		for _, dc := range seqItems(dcs) {
+			clusterGroups(mapValue(dc, "clusterGroups"))
+		}
+	}
+	selectors := func(items *yaml3.Node, keys ...string) {
+		for _, item := range seqItems(items) {
+			for _, k := range keys {
+				selectorToString(mapValue(item, k))
+			}
+		}
+	}
+
+	switch kind {
+	case TopologyKind:
+		clusterGroups(mapValue(doc, "clustergroups"))
+	case DatacentersKind, DCMappingKind:
+		datacenters(mapValue(doc, "datacenters"))
+	case XDCRKind:
+		selectors(mapValue(doc, "xdcrdefs"), "source", "sourceExclude", "destination", "destinationExclude", "hub")
+	case PolicyKind:
+		selectors(mapValue(doc, "policies"), "buckets", "forbidSource", "forbidDestination")
+	case StyleKind:
+		selectors(mapValue(doc, "nodes"), "selector")
+		selectors(mapValue(doc, "clusters"), "selector")
+	}
+}
+
+// mapValue returns the value of the key of a mapping node, nil if absent. Keys are matched case insensitively as
+// encoding/json does.
+func mapValue(n *yaml3.Node, key string) *yaml3.Node {
+	if n == nil || n.Kind != yaml3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if strings.EqualFold(n.Content[i].Value, key) {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMapValue(n *yaml3.Node, key, value string) {
+	if v := mapValue(n, key); v != nil {
+		v.Kind, v.Tag, v.Value, v.Style = yaml3.ScalarNode, "!!str", value, 0
+		return
+	}
+	n.Content = append(n.Content,
+		&yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: key},
+		&yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: value})
+}
+
+func renameKey(n *yaml3.Node, from, to string) {
+	if n == nil || n.Kind != yaml3.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if strings.EqualFold(n.Content[i].Value, from) {
+			n.Content[i].Value = to
+		}
+	}
+}
+
+func seqItems(n *yaml3.Node) []*yaml3.Node {
+	if n == nil || n.Kind != yaml3.SequenceNode {
+		return nil
+	}
+	return n.Content
+}
+
+// selectorToString rewrites a selector map as a "k=v,k2=v2" string, unless a term can not be written so: the parsing
+// of the string splits on commas and equal signs and trims the keys and values, empty ones are kept as a map as well.
+func selectorToString(n *yaml3.Node) {
+	if n == nil || n.Kind != yaml3.MappingNode || len(n.Content) == 0 {
+		return
+	}
+	terms := []string{}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i].Value, n.Content[i+1].Value
+		if n.Content[i+1].Kind != yaml3.ScalarNode || strings.ContainsAny(k+v, ",=") {
+			return
+		}
+		if k == "" || v == "" || strings.TrimSpace(k) != k || strings.TrimSpace(v) != v {
+			return
+		}
+		terms = append(terms, k+"="+v)
+	}
+	*n = yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: strings.Join(terms, ","), HeadComment: n.HeadComment, LineComment: n.LineComment, FootComment: n.FootComment, Line: n.Line, Column: n.Column}
+}
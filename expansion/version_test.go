@@ -0,0 +1,231 @@
+package expansion
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFile writes the content in a file of a temporary folder and returns its path.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(file, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestMigrateFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "topology renames",
+			file: "couchbase.yaml",
+			content: `clustergroups:
+- name: CG
+  peakToken: [LH, AF]
+  clusters:
+  - name: C
+    buckets:
+    - name: B
+      cbReplicatNumber: 1
+`,
+			want:    []string{"kind: Topology\n", "apiVersion: couchbaseblueprint/v2\n", "peakTokens: [LH, AF]", "cbReplicaNumber: 1"},
+			notWant: []string{"peakToken:", "cbReplicatNumber"},
+		},
+		{
+			name: "datacenter override renames",
+			file: "datacenters.yaml",
+			content: `datacenters:
+- name: DC1
+  clusterGroups:
+  - name: CG
+    peakToken: [LH]
+    clusters:
+    - name: C
+      buckets:
+      - name: B
+        cbReplicatNumber: 2
+`,
+			want:    []string{"kind: Datacenters\n", "peakTokens: [LH]", "cbReplicaNumber: 2"},
+			notWant: []string{"peakToken:", "cbReplicatNumber"},
+		},
+		{
+			name: "selectors",
+			file: "XDCR.yaml",
+			content: `xdcrdefs:
+- rule: custom
+  source: {Role: Resa, Level: "2"}
+  sourceExclude: {Zone: a b}
+  destination:
+    Role: Stat
+  hub: {Role: "a,b"}
+  destinationExclude: {Role: "a=b"}
+- rule: ring
+  source: {Role: " padded"}
+  sourceExclude: {Role: ""}
+  destination: {Role: [a, b]}
+`,
+			want: []string{
+				"source: Role=Resa,Level=2\n",
+				"sourceExclude: Zone=a b\n",
+				"destination: Role=Stat\n",
+				// the terms that the string syntax can not express stay maps
+				`hub: {Role: "a,b"}`,
+				`destinationExclude: {Role: "a=b"}`,
+				`source: {Role: " padded"}`,
+				`sourceExclude: {Role: ""}`,
+				"destination: {Role: [a, b]}",
+			},
+		},
+		{
+			name: "policy selectors",
+			file: "policies.yaml",
+			content: `policies:
+- name: p
+  buckets: {Role: Resa}
+  forbidSource: {Environment: prod}
+  forbidDestination: {Environment: test}
+`,
+			want: []string{"kind: Policy\n", "buckets: Role=Resa\n", "forbidSource: Environment=prod\n", "forbidDestination: Environment=test\n"},
+		},
+		{
+			name: "comments",
+			file: "XDCR.yaml",
+			content: `# replications of the resa buckets
+xdcrdefs: # one per role
+# the ring
+- rule: ring # around the datacenters
+  source: {Role: Resa} # the resa buckets
+`,
+			want: []string{"# replications of the resa buckets\n", "xdcrdefs: # one per role\n", "# the ring\n", "rule: ring # around the datacenters\n", "source: Role=Resa # the resa buckets\n"},
+		},
+		{
+			name:    "json",
+			file:    "XDCR.json",
+			content: `{"xdcrdefs": [{"rule": "ring", "source": {"Role": "Resa"}}]}`,
+			want:    []string{"{\n\t\"apiVersion\": \"couchbaseblueprint/v2\",\n\t\"kind\": \"XDCR\",\n\t\"xdcrdefs\": [\n\t\t{\n\t\t\t\"rule\": \"ring\",\n\t\t\t\"source\": \"Role=Resa\"\n\t\t}\n\t]\n}\n"},
+			notWant: []string{"  "},
+		},
+		{
+			name:    "latest version",
+			file:    "XDCR.yaml",
+			content: "apiVersion: couchbaseblueprint/v2\nkind: XDCR\nxdcrdefs:\n- rule: ring\n  source: {Role: Resa}\n",
+			want:    []string{"apiVersion: couchbaseblueprint/v2\nkind: XDCR\n", "source: {Role: Resa}"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := MigrateFile(writeTestFile(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(b), want) {
+					t.Errorf("MigrateFile() does not write %q:\n%s", want, b)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(b), notWant) {
+					t.Errorf("MigrateFile() writes %q:\n%s", notWant, b)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateFileErrors(t *testing.T) {
+	tests := []struct {
+		name, file, content, want string
+	}{
+		{"unknown version", "XDCR.yaml", "apiVersion: couchbaseblueprint/v9\nxdcrdefs: []\n", `XDCR.yaml:1:1: unknown apiVersion "couchbaseblueprint/v9", expected one of [couchbaseblueprint/v1 couchbaseblueprint/v2]`},
+		{"unknown kind", "XDCR.yaml", "unknown: []\n", "XDCR.yaml: unknown kind of file"},
+		{"toml", "XDCR.toml", "xdcrdefs = []\n", "can not migrate toml files"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MigrateFile(writeTestFile(t, tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("MigrateFile() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestUpgradedPositions checks that the problems of a v1 file are reported on its own lines, not on the ones of its
+// upgraded content.
+func TestUpgradedPositions(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		line    int
+		column  int
+		snippet string
+	}{
+		{
+			name: "yaml",
+			file: "couchbase.yaml",
+			content: `clustergroups:
+- name: CG
+  peakToken: [LH]
+  clusters:
+  - name: C
+    buckets:
+    - name: B
+      cbReplicatNumber: 1
+      ramQuota: abc
+`,
+			line: 9, column: 7, snippet: "      ramQuota: abc",
+		},
+		{
+			name: "yaml unknown key",
+			file: "couchbase.yaml",
+			content: `# topology
+clustergroups:
+  - name: CG
+    peakToken: [LH]
+    clusters:
+        - name: C
+          instance: ["0"]
+`,
+			line: 7, column: 11, snippet: `          instance: ["0"]`,
+		},
+		{
+			name: "json",
+			file: "couchbase.json",
+			content: `{
+  "clustergroups": [
+    {
+      "name": "CG",
+      "peakToken": ["LH"],
+      "clusters": [{"name": "C", "buckets": [{"name": "B",
+        "ramQuota": "abc"}]}]
+    }
+  ]
+}
+`,
+			line: 7, column: 9, snippet: `        "ramQuota": "abc"}]}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := TopoFromFile(writeTestFile(t, tt.file, tt.content), nil)
+			fe, ok := err.(*FileError)
+			if !ok || len(fe.Problems) != 1 {
+				t.Fatalf("TopoFromFile() error = %v, want one problem", err)
+			}
+			p := fe.Problems[0]
+			if p.Line != tt.line || p.Column != tt.column || p.Snippet != tt.snippet {
+				t.Errorf("TopoFromFile() problem at %d:%d %q, want %d:%d %q", p.Line, p.Column, p.Snippet, tt.line, tt.column, tt.snippet)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package expansion
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// View selects the part of a blueprint shown by a graph. The zero value shows the whole blueprint.
+type View struct {
+	// Buckets keeps the buckets matching the selector, all when nil
+	Buckets model.Selector
+	// Definitions keeps the replications of these definitions, all when empty. A reference without file designates the
+	// definition of that index in every XDCR file.
+	Definitions []DefinitionRef
+	// Focus keeps the buckets at most Hops replications away from the bucket of this path, in either direction
+	Focus string
+	Hops  int
+	// Collapse folds each cluster, cluster group or datacenter into a single node, the replications between two nodes
+	// are aggregated into one edge labelled with their number
+	Collapse model.BoxKind
+}
+
+// ParseView reads a view from its textual settings, as given by the command line flags and the query parameters:
+// a selector, comma separated definitions written index or file#index, the folded level, and the focused bucket
+// path with its number of hops.
+func ParseView(buckets, definitions, collapse, focus string, hops int) (View, error) {
+	v := View{Focus: focus, Hops: hops, Collapse: model.BoxKind(collapse)}
+	if buckets != "" {
+		s, err := model.ParseSelector(buckets)
+		if err != nil {
+			return v, err
+		}
+		v.Buckets = s
+	}
+	for _, term := range strings.Split(definitions, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		ref := DefinitionRef{}
+		if i := strings.LastIndex(term, "#"); i >= 0 {
+			ref.File, term = strings.TrimSpace(term[:i]), strings.TrimSpace(term[i+1:])
+		}
+		index, err := strconv.Atoi(term)
+		if err != nil || index < 0 {
+			return v, fmt.Errorf("Invalid definition %q, expected index or file#index", term)
+		}
+		ref.Index = index
+		v.Definitions = append(v.Definitions, ref)
+	}
+	return v, v.Validate()
+}
+
+// Validate checks the view.
+func (v View) Validate() error {
+	switch v.Collapse {
+	case "", model.ClusterBox, model.ClusterGroupBox, model.DatacenterBox:
+	default:
+		return fmt.Errorf("Invalid collapse %q, expected %s, %s or %s", v.Collapse, model.ClusterBox, model.ClusterGroupBox, model.DatacenterBox)
+	}
+	if v.Hops < 0 {
+		return fmt.Errorf("Invalid hops %d, expected a positive number", v.Hops)
+	}
+	return nil
+}
+
+// IsZero tells if the view shows the whole blueprint.
+func (v View) IsZero() bool {
+	return v.Buckets == nil && len(v.Definitions) == 0 && v.Focus == "" && v.Collapse == ""
+}
+
+// selects tells if the view keeps the replications of the definition of the set.
+func (v View) selects(set XDCRSet, index int) bool {
+	if len(v.Definitions) == 0 {
+		return true
+	}
+	for _, ref := range v.Definitions {
+		if ref.Index == index && (ref.File == "" || ref.File == set.File || ref.File == filepath.Base(set.File)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the datacenters and the replications of the blueprint shown by the view. The boxes left without
+// bucket are removed.
+func (v View) Apply(bp *Blueprint) ([]model.Datacenter, []model.XDCR, error) {
+	if err := v.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	kept := map[string]bool{}
+	for _, dc := range bp.Datacenters {
+		for _, b := range dc.GetBuckets() {
+			if v.Buckets == nil || b.Match(v.Buckets) {
+				kept[b.Path()] = true
+			}
+		}
+	}
+	xdcrs := []model.XDCR{}
+	for _, set := range bp.XDCRSets {
+		for i, replications := range set.Replications() {
+			if !v.selects(set, i) {
+				continue
+			}
+			for _, x := range replications {
+				if kept[x.Source.Path()] && kept[x.Destination.Path()] {
+					xdcrs = append(xdcrs, x)
+				}
+			}
+		}
+	}
+
+	if v.Focus != "" {
+		if !kept[v.Focus] {
+			return nil, nil, fmt.Errorf("Invalid focus %q, no bucket of the view has this path", v.Focus)
+		}
+		kept = neighbourhood(v.Focus, v.Hops, xdcrs)
+		focused := []model.XDCR{}
+		for _, x := range xdcrs {
+			if kept[x.Source.Path()] && kept[x.Destination.Path()] {
+				focused = append(focused, x)
+			}
+		}
+		xdcrs = focused
+	}
+
+	dcs := pruneDatacenters(bp.Datacenters, kept)
+	if v.Collapse == "" {
+		return dcs, xdcrs, nil
+	}
+	dcs, nodes := collapseDatacenters(dcs, v.Collapse)
+	return dcs, aggregateXDCRs(xdcrs, nodes, func(l Link) string { return strconv.Itoa(l.Replications) }), nil
+}
+
+// neighbourhood returns the paths of the buckets at most hops replications away from the bucket of the path.
+func neighbourhood(path string, hops int, xdcrs []model.XDCR) map[string]bool {
+	adjacent := map[string][]string{}
+	for _, x := range xdcrs {
+		s, d := x.Source.Path(), x.Destination.Path()
+		adjacent[s] = append(adjacent[s], d)
+		adjacent[d] = append(adjacent[d], s)
+	}
+	reached := map[string]bool{path: true}
+	frontier := []string{path}
+	for hop := 0; hop < hops && len(frontier) > 0; hop++ {
+		next := []string{}
+		for _, p := range frontier {
+			for _, q := range adjacent[p] {
+				if !reached[q] {
+					reached[q] = true
+					next = append(next, q)
+				}
+			}
+		}
+		frontier = next
+	}
+	return reached
+}
+
+// pruneDatacenters returns the datacenters with the kept buckets only, without the boxes left empty.
+func pruneDatacenters(dcs []model.Datacenter, kept map[string]bool) []model.Datacenter {
+	result := []model.Datacenter{}
+	for _, dc := range dcs {
+		cgs := []model.ClusterGroup{}
+		for _, cg := range dc.ClusterGroups {
+			clusters := []model.Cluster{}
+			for _, c := range cg.Clusters {
+				buckets := []model.Bucket{}
+				for _, b := range c.Buckets {
+					if kept[b.Path()] {
+						buckets = append(buckets, b)
+					}
+				}
+				if len(buckets) > 0 {
+					c.Buckets = buckets
+					clusters = append(clusters, c)
+				}
+			}
+			if len(clusters) > 0 {
+				cg.Clusters = clusters
+				cgs = append(cgs, cg)
+			}
+		}
+		if len(cgs) > 0 {
+			dc.ClusterGroups = cgs
+			result = append(result, dc)
+		}
+	}
+	return result
+}
+
+// collapseDatacenters replaces the buckets of each cluster, cluster group or datacenter by a single bucket standing for
+// its box. It returns the datacenters and the bucket standing for each original bucket path.
+func collapseDatacenters(dcs []model.Datacenter, level model.BoxKind) ([]model.Datacenter, map[string]model.Bucket) {
+	nodes := map[string]model.Bucket{}
+	fold := func(name string, labels model.Labels, buckets []model.Bucket) model.Bucket {
+		node := model.Bucket{Name: name, Labels: labels}
+		for _, b := range buckets {
+			node.RamQuota += b.RamQuota
+		}
+		for _, b := range buckets {
+			nodes[b.Path()] = node
+		}
+		return node
+	}
+
+	result := []model.Datacenter{}
+	for _, dc := range dcs {
+		if level == model.DatacenterBox {
+			labels := dc.BoxLabels()
+			labels["ClusterGroup"], labels["Cluster"] = "", ""
+			node := fold(dc.Name, labels, dc.GetBuckets())
+			cluster := model.Cluster{Labels: model.Labels{"Datacenter": dc.Name}, Buckets: []model.Bucket{node}}
+			dc.ClusterGroups = []model.ClusterGroup{{Labels: model.Labels{"Datacenter": dc.Name}, Clusters: []model.Cluster{cluster}}}
+			result = append(result, dc)
+			continue
+		}
+		cgs := []model.ClusterGroup{}
+		for _, cg := range dc.ClusterGroups {
+			if level == model.ClusterGroupBox {
+				buckets := []model.Bucket{}
+				for _, c := range cg.Clusters {
+					buckets = append(buckets, c.Buckets...)
+				}
+				labels := cg.BoxLabels()
+				labels["Cluster"] = ""
+				node := fold(strings.TrimSuffix(cg.Name+"_"+cg.PeakToken, "_"), labels, buckets)
+				cg.Clusters = []model.Cluster{{Name: cg.Name, Instance: cg.PeakToken, Labels: cg.BoxLabels(), Buckets: []model.Bucket{node}}}
+				cgs = append(cgs, cg)
+				continue
+			}
+			clusters := []model.Cluster{}
+			for _, c := range cg.Clusters {
+				name := strings.TrimSuffix(c.Name+"_"+c.Instance, "_")
+				c.Buckets = []model.Bucket{fold(name, c.BoxLabels(), c.Buckets)}
+				clusters = append(clusters, c)
+			}
+			cg.Clusters = clusters
+			cgs = append(cgs, cg)
+		}
+		dc.ClusterGroups = cgs
+		result = append(result, dc)
+	}
+	return result, nodes
+}
+
+// aggregateXDCRs returns one replication per pair of distinct nodes, in the order of their first replication and
+// labelled from the link they aggregate, the replications within a node are dropped. The color is kept when all the
+// replications of the pair agree on it, left empty for the renderers to use their default otherwise.
+func aggregateXDCRs(xdcrs []model.XDCR, nodes map[string]model.Bucket, label func(Link) string) []model.XDCR {
+	type pair struct {
+		source, destination string
+	}
+	byPair := map[pair]int{}
+	links := []Link{}
+	result := []model.XDCR{}
+	for _, x := range xdcrs {
+		s, d := nodes[x.Source.Path()], nodes[x.Destination.Path()]
+		p := pair{s.Path(), d.Path()}
+		if p.source == p.destination {
+			continue
+		}
+		i, ok := byPair[p]
+		if !ok {
+			i = len(result)
+			byPair[p] = i
+			links = append(links, Link{Source: p.source, Destination: p.destination})
+			result = append(result, model.XDCR{Source: s, Destination: d, Color: x.Color, Priority: x.Priority})
+		}
+		links[i].Replications++
+		links[i].RamQuota += x.Source.RamQuota
+		if result[i].Color != x.Color {
+			result[i].Color = ""
+		}
+		if x.Priority > result[i].Priority {
+			result[i].Priority = x.Priority
+		}
+	}
+	for i := range result {
+		result[i].Label = label(links[i])
+		_, result[i].Bidirectional = byPair[pair{result[i].Destination.Path(), result[i].Source.Path()}]
+	}
+	return result
+}
@@ -0,0 +1,149 @@
+package expansion
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func TestAggregateXDCRs(t *testing.T) {
+	a1, a2 := testBucket("DC1", "A1"), testBucket("DC1", "A2")
+	b1, c1 := testBucket("DC2", "B1"), testBucket("DC3", "C1")
+	dcA, dcB, dcC := model.Bucket{Name: "DC1"}, model.Bucket{Name: "DC2"}, model.Bucket{Name: "DC3"}
+	nodes := map[string]model.Bucket{a1.Path(): dcA, a2.Path(): dcA, b1.Path(): dcB, c1.Path(): dcC}
+	xdcrs := []model.XDCR{
+		{Source: a1, Destination: b1, Color: "red"},
+		{Source: a2, Destination: b1, Color: "red", Priority: 2},
+		{Source: a1, Destination: c1, Color: "red"},
+		{Source: a2, Destination: c1, Color: "blue"},
+		{Source: a1, Destination: a2, Color: "green"},
+		{Source: b1, Destination: a1, Color: "red"},
+	}
+	got := aggregateXDCRs(xdcrs, nodes, func(l Link) string { return l.Source + ">" + l.Destination })
+	type edge struct {
+		source, destination, color, label string
+		priority                          int
+		bidirectional                     bool
+	}
+	want := []edge{
+		{"___DC1", "___DC2", "red", "___DC1>___DC2", 2, true},
+		// the colors differ, the renderers use their default
+		{"___DC1", "___DC3", "", "___DC1>___DC3", 0, false},
+		{"___DC2", "___DC1", "red", "___DC2>___DC1", 0, true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("aggregateXDCRs() = %v, want %d replications", got, len(want))
+	}
+	for i, x := range got {
+		e := edge{x.Source.Path(), x.Destination.Path(), x.Color, x.Label, x.Priority, x.Bidirectional}
+		if e != want[i] {
+			t.Errorf("aggregateXDCRs()[%d] = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+// testViewBlueprint returns the chain A->B->C->D->E of the definitions #0 to #3 of XDCR.yaml, and the replication
+// E->A of the definition #0 of other/replications.yaml.
+func testViewBlueprint() *Blueprint {
+	buckets := []model.Bucket{
+		testBucket("DC1", "A", "Role", "resa"),
+		testBucket("DC1", "B", "Role", "resa"),
+		testBucket("DC1", "C", "Role", "stat"),
+		testBucket("DC1", "D", "Role", "resa"),
+		testBucket("DC1", "E", "Role", "stat"),
+	}
+	bp := testBlueprint(buckets, "A->B", "B->C", "C->D", "D->E")
+	other := testBlueprint(buckets, "E->A").XDCRSets[0]
+	other.File = "other/replications.yaml"
+	bp.XDCRSets = append(bp.XDCRSets, other)
+	return bp
+}
+
+func TestViewApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		buckets     string
+		definitions string
+		focus       string
+		hops        int
+		// wantBuckets and wantXDCRs are the bucket names and the replications written A->B
+		wantBuckets []string
+		wantXDCRs   []string
+	}{
+		{"all", "", "", "", 0, []string{"A", "B", "C", "D", "E"}, []string{"A->B", "B->C", "C->D", "D->E", "E->A"}},
+		{"select", "Role=resa", "", "", 0, []string{"A", "B", "D"}, []string{"A->B"}},
+		{"select none", "Role=none", "", "", 0, []string{}, []string{}},
+		{"index in every file", "", "0", "", 0, []string{"A", "B", "C", "D", "E"}, []string{"A->B", "E->A"}},
+		{"file#index", "", "XDCR.yaml#0, XDCR.yaml#2", "", 0, []string{"A", "B", "C", "D", "E"}, []string{"A->B", "C->D"}},
+		{"file path#index", "", "other/replications.yaml#0", "", 0, []string{"A", "B", "C", "D", "E"}, []string{"E->A"}},
+		{"file name#index", "", "replications.yaml#0", "", 0, []string{"A", "B", "C", "D", "E"}, []string{"E->A"}},
+		{"unknown file", "", "unknown.yaml#0", "", 0, []string{"A", "B", "C", "D", "E"}, []string{}},
+		{"focus 0 hops", "", "", "DC1___C", 0, []string{"C"}, []string{}},
+		{"focus 1 hop", "", "", "DC1___C", 1, []string{"B", "C", "D"}, []string{"B->C", "C->D"}},
+		{"focus 1 hop in a chain", "", "XDCR.yaml#0,XDCR.yaml#1,XDCR.yaml#2,XDCR.yaml#3", "DC1___A", 1, []string{"A", "B"}, []string{"A->B"}},
+		{"focus 2 hops in a chain", "", "XDCR.yaml#0,XDCR.yaml#1,XDCR.yaml#2,XDCR.yaml#3", "DC1___A", 2, []string{"A", "B", "C"}, []string{"A->B", "B->C"}},
+		// the replications between the kept buckets are kept, whatever their distance to the focus
+		{"focus 2 hops", "", "", "DC1___C", 2, []string{"A", "B", "C", "D", "E"}, []string{"A->B", "B->C", "C->D", "D->E", "E->A"}},
+		{"focus in selection", "Role=resa", "", "DC1___A", 5, []string{"A", "B"}, []string{"A->B"}},
+		{"focus in definitions", "", "XDCR.yaml#1,XDCR.yaml#2", "DC1___B", 1, []string{"B", "C"}, []string{"B->C"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := ParseView(tt.buckets, tt.definitions, "", tt.focus, tt.hops)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dcs, xdcrs, err := v.Apply(testViewBlueprint())
+			if err != nil {
+				t.Fatal(err)
+			}
+			buckets := []string{}
+			for _, dc := range dcs {
+				for _, b := range dc.GetBuckets() {
+					buckets = append(buckets, b.Name)
+				}
+			}
+			if !reflect.DeepEqual(buckets, tt.wantBuckets) {
+				t.Errorf("Apply() buckets = %v, want %v", buckets, tt.wantBuckets)
+			}
+			edges := []string{}
+			for _, x := range xdcrs {
+				edges = append(edges, x.Source.Name+"->"+x.Destination.Name)
+			}
+			if !reflect.DeepEqual(edges, tt.wantXDCRs) {
+				t.Errorf("Apply() replications = %v, want %v", edges, tt.wantXDCRs)
+			}
+		})
+	}
+}
+
+func TestViewErrors(t *testing.T) {
+	tests := []struct {
+		name                                  string
+		buckets, definitions, collapse, focus string
+		hops                                  int
+		want                                  string
+	}{
+		{"selector", "Role", "", "", "", 0, `Invalid selector term "Role", expected key=value`},
+		{"definition", "", "x", "", "", 0, `Invalid definition "x", expected index or file#index`},
+		{"negative definition", "", "-1", "", "", 0, `Invalid definition "-1", expected index or file#index`},
+		{"definition of file", "", "XDCR.yaml#", "", "", 0, `Invalid definition "", expected index or file#index`},
+		{"collapse", "", "", "bucket", "", 0, `Invalid collapse "bucket", expected cluster, clustergroup or datacenter`},
+		{"hops", "", "", "", "DC1___A", -1, "Invalid hops -1, expected a positive number"},
+		{"focus", "", "", "", "DC1___X", 1, `Invalid focus "DC1___X", no bucket of the view has this path`},
+		{"focus out of selection", "Role=stat", "", "", "DC1___A", 1, `Invalid focus "DC1___A", no bucket of the view has this path`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := ParseView(tt.buckets, tt.definitions, tt.collapse, tt.focus, tt.hops)
+			if err == nil {
+				_, _, err = v.Apply(testViewBlueprint())
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseView().Apply() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
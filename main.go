@@ -1,204 +1,678 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"html/template"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
+	"time"
 
-	"github.com/gorilla/mux"
-
+	"github.com/dbenque/couchbaseblueprint/expansion"
+	"github.com/dbenque/couchbaseblueprint/model"
+	"github.com/dbenque/couchbaseblueprint/render"
+	"github.com/dbenque/couchbaseblueprint/report"
+	"github.com/dbenque/couchbaseblueprint/server"
+	"github.com/dbenque/couchbaseblueprint/watch"
 	"gopkg.in/yaml.v2"
 )
 
-type DCInjector struct {
-	Topos map[string][]string
-	XDCRs map[string][]string
+const programName = "couchbaseblueprint"
+
+// Exit codes of the command line.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// errUsage is returned by a command when its arguments are invalid, the usage of the command is then printed.
+var errUsage = errors.New("invalid usage")
+
+type command struct {
+	Name    string
+	Args    string
+	Summary string
+	// Setup declares the flags of the command and returns the function running it
+	Setup func(fs *flag.FlagSet, stdout io.Writer) func(args []string) error
+}
+
+var commands = []command{
+	{Name: "render", Args: "", Summary: "expand a blueprint and write its graph", Setup: renderCommand},
+	{Name: "serve", Args: "", Summary: "start the web server", Setup: serveCommand},
+	{Name: "validate", Args: "", Summary: "check a blueprint and report its problems", Setup: validateCommand},
+	{Name: "export", Args: "", Summary: "write the expanded datacenters and replications as json or yaml", Setup: exportCommand},
+	{Name: "sample", Args: "", Summary: "generate the built-in sample blueprints and write their graph", Setup: sampleCommand},
+	{Name: "explain", Args: "<source bucket> <destination bucket>", Summary: "explain why a replication exists or not", Setup: explainCommand},
+	{Name: "query", Args: "[selector]", Summary: "list the buckets matching a selector such as Role=Rbox,Datacenter=DC2", Setup: queryCommand},
+	{Name: "report", Args: "", Summary: "write a markdown or html report of a blueprint", Setup: reportCommand},
+	{Name: "physical", Args: "", Summary: "sum the replications and their RAM quota per pair of clusters or datacenters", Setup: physicalCommand},
+	{Name: "schema", Args: "<name>", Summary: fmt.Sprintf("write the JSON Schema of an input file %v", expansion.SchemaNames()), Setup: schemaCommand},
+	{Name: "migrate", Args: "<file>...", Summary: "rewrite blueprint files for the latest apiVersion", Setup: migrateCommand},
+	{Name: "conflicts", Args: "", Summary: "check the conflict resolution of the active-active replication cycles", Setup: conflictsCommand},
+	{Name: "watch", Args: "", Summary: "rebuild a blueprint on change and serve its latest graph with live reload", Setup: watchCommand},
 }
 
 func main() {
+	os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	if len(os.Args) == 1 {
-		gen_sample1()
-		gen_RBox1()
-		return
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
 	}
-
-	// From DC File
-	if len(os.Args) == 2 {
-		if os.Args[1] == "server" {
-			templates = template.Must(template.New("abc").Funcs(fns).ParseGlob("public/template/*.html"))
-			r := mux.NewRouter()
-			r.HandleFunc("/main", mainPage)
-			r.HandleFunc("/users", usersPage)
-			r.HandleFunc("/topo", dcTopoPageForm)
-			r.HandleFunc("/datacenters", datacentersPage)
-			r.HandleFunc("/datacenter/{datacenterName}", dcPage)
-			r.HandleFunc("/newdatacenter", newDatacenterPage)
-			r.HandleFunc("/topo/{user}/datacenter/{datacenterName}", dcTopoPage)
-			r.HandleFunc("/uploadTopo/{user}/datacenter/{dcname}", dcUploadTopo)
-			r.PathPrefix("/").Handler(http.FileServer(http.Dir("./public/")))
-			http.Handle("/", r)
-			http.ListenAndServe(":1323", nil)
-		} else {
-			FromDCFile(os.Args[1])
+	name := args[0]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		if len(args) > 1 {
+			if c, ok := findCommand(args[1]); ok {
+				fs := c.newFlagSet(stdout)
+				c.Setup(fs, stdout)
+				commandUsage(stdout, c, fs)
+				return exitOK
+			}
 		}
-		return
+		usage(stdout)
+		return exitOK
 	}
 
-	// From folder
+	c, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q\n\n", name)
+		usage(stderr)
+		return exitUsage
+	}
 
-	if (len(os.Args) != 3 && len(os.Args) != 4) || (os.Args[1] != "yaml" && os.Args[1] != "json") {
-		fmt.Println("First parameter must be input format [yaml|json] and the second parameter must be the folder containing the files (couchbase.yaml and XDCR.yaml). Optional last param, Datacenter count")
+	fs := c.newFlagSet(stderr)
+	run := c.Setup(fs, stdout)
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
 	}
-	dcCount := 1
-	if len(os.Args) == 4 {
-		var err error
-		dcCount, err = strconv.Atoi(os.Args[3])
-		if err != nil {
-			fmt.Println("Error with datacenter counter. Last parameter should be a number ")
+	if err := run(fs.Args()); err != nil {
+		if err == errUsage {
+			commandUsage(stderr, c, fs)
+			return exitUsage
 		}
+		if fe, ok := err.(*expansion.FileError); ok {
+			for _, p := range fe.Problems {
+				fmt.Fprintf(stderr, "%s %s: %s\n", programName, c.Name, p.String())
+				if ctx := p.Context(); ctx != "" {
+					fmt.Fprintln(stderr, ctx)
+				}
+			}
+			return exitError
+		}
+		fmt.Fprintf(stderr, "%s %s: %v\n", programName, c.Name, err)
+		return exitError
 	}
-	DCs := []Datacenter{}
-	for i := 0; i < dcCount; i++ {
-		DCs = append(DCs, NewDatacenter(fmt.Sprintf("DC%d", i+1)))
+	return exitOK
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.Name == name {
+			return c, true
+		}
 	}
-	FromFolder(os.Args[2], os.Args[1], DCs)
+	return command{}, false
+}
 
+func (c command) newFlagSet(w io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(programName+" "+c.Name, flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.Usage = func() { commandUsage(w, c, fs) }
+	return fs
 }
 
-func ToFile(v interface{}, filePath string) {
-	//json
-	{
-		b, _ := json.Marshal(v)
-		var out bytes.Buffer
-		json.Indent(&out, b, " ", "\t")
-		ioutil.WriteFile(filePath+".json", out.Bytes(), 0777)
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", programName)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.Name, c.Summary)
 	}
-	//yaml
-	{
-		y, _ := yaml.Marshal(v)
-		ioutil.WriteFile(filePath+".yaml", y, 0777)
+	fmt.Fprintf(w, "\nRun '%s help <command>' for the flags of a command.\n", programName)
+}
 
-	}
+func commandUsage(w io.Writer, c command, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n", programName, c.Name, c.Args, c.Summary)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
 }
 
-func TopoFromFile(file string, DCs []Datacenter, w io.Writer) (error, []Datacenter) {
-	format := strings.Split(file, ".")[1]
-	b, err := ioutil.ReadFile(file)
-	if err != nil {
-		fmt.Println(err)
-		return err, nil
+// blueprintFlags are the input flags shared by the commands reading a blueprint.
+type blueprintFlags struct {
+	in          string
+	inputFormat string
+	dcCount     int
+}
+
+func addBlueprintFlags(fs *flag.FlagSet) *blueprintFlags {
+	bf := &blueprintFlags{}
+	fs.StringVar(&bf.in, "in", "", "blueprint folder containing couchbase.<format> and XDCR.<format>, or DC mapping file, - for the standard input")
+	fs.StringVar(&bf.inputFormat, "input-format", "", fmt.Sprintf("format of the files of a blueprint folder %v, detected from the extensions when empty", expansion.FormatNames()))
+	fs.IntVar(&bf.dcCount, "dc", 1, "number of datacenters DC1..DCn a blueprint folder is expanded into")
+	return bf
+}
+
+func (bf *blueprintFlags) load() (*expansion.Blueprint, error) {
+	if bf.in == "" {
+		return nil, errUsage
 	}
-	var cgdefBlueprint ClusterGroupDefBluePrint
-	switch format {
-	case "json":
-		err = json.Unmarshal(b, &cgdefBlueprint)
-	case "yaml":
-		err = yaml.Unmarshal(b, &cgdefBlueprint)
+	if bf.in == expansion.Stdin {
+		return expansion.BlueprintFromDCFile(bf.in)
 	}
+	fi, err := os.Stat(bf.in)
 	if err != nil {
-		fmt.Println(err)
-		return err, nil
+		return nil, err
 	}
+	if !fi.IsDir() {
+		return expansion.BlueprintFromDCFile(bf.in)
+	}
+	if bf.inputFormat != "" && expansion.FormatExtension(bf.inputFormat) == "" {
+		return nil, fmt.Errorf("Invalid input format %q, expected one of %v", bf.inputFormat, expansion.FormatNames())
+	}
+	if bf.dcCount < 1 {
+		return nil, fmt.Errorf("Invalid datacenter count %d, it must be at least 1", bf.dcCount)
+	}
+	DCs := []model.Datacenter{}
+	for i := 0; i < bf.dcCount; i++ {
+		DCs = append(DCs, model.NewDatacenter(fmt.Sprintf("DC%d", i+1)))
+	}
+	return expansion.BlueprintFromFolder(bf.in, bf.inputFormat, DCs)
+}
 
-	for _, d := range cgdefBlueprint.ClusterGroups {
-		for i := range DCs {
-			DCs[i].AddClusterGroupDef(d)
+// renderFlags are the options of the graph shared by the commands rendering a blueprint.
+type renderFlags struct {
+	options       render.Options
+	clusterColors string
+	style         string
+}
+
+func addRenderFlags(fs *flag.FlagSet) *renderFlags {
+	rf := &renderFlags{}
+	defaults := render.DefaultOptions()
+	fs.StringVar(&rf.options.RankBy, "rank-by", defaults.RankBy, "label whose buckets having the same value are put on the same rank, none if empty")
+	fs.StringVar(&rf.options.Direction, "direction", "", fmt.Sprintf("direction of the layout %v, the default of the format if empty", render.Directions))
+	fs.BoolVar(&rf.options.ShowLabels, "show-labels", false, "show the labels of the buckets")
+	fs.StringVar(&rf.clusterColors, "cluster-colors", "", "comma separated colors of the datacenter boxes, cycled over the datacenters")
+	fs.StringVar(&rf.style, "style", "", "file mapping label selectors to the styles of the nodes, boxes and edges")
+	return rf
+}
+
+// renderer returns the renderer configured with the options of the flags.
+func (rf *renderFlags) renderer(r render.Renderer) (render.Renderer, error) {
+	o := rf.options
+	if rf.clusterColors != "" {
+		o.ClusterColors = strings.Split(rf.clusterColors, ",")
+	}
+	if rf.style != "" {
+		style, err := expansion.StyleFromFile(rf.style)
+		if err != nil {
+			return nil, err
 		}
+		o.Style = style
 	}
-
-	for i := range DCs {
-		DCs[i].Dot(w)
+	if err := o.Validate(); err != nil {
+		return nil, err
 	}
+	return render.WithOptions(r, o), nil
+}
 
-	return nil, DCs
+// viewFlags select the part of the blueprint rendered.
+type viewFlags struct {
+	buckets     string
+	definitions string
+	collapse    string
+	focus       string
+	hops        int
 }
 
-func XDCRFromFile(file string, DCs []Datacenter, w io.Writer) {
+func addViewFlags(fs *flag.FlagSet) *viewFlags {
+	vf := &viewFlags{}
+	fs.StringVar(&vf.buckets, "select", "", "show only the buckets matching the selector such as Role=Rbox,Datacenter=DC2")
+	fs.StringVar(&vf.definitions, "definitions", "", "show only the replications of the comma separated XDCR definitions, written index or file#index")
+	fs.StringVar(&vf.collapse, "collapse", "", "fold each cluster, clustergroup or datacenter into a single node with aggregated edges")
+	fs.StringVar(&vf.focus, "focus", "", "show only the neighbourhood of the bucket of this path")
+	fs.IntVar(&vf.hops, "hops", 1, "number of replications from the focused bucket shown")
+	return vf
+}
 
-	format := strings.Split(file, ".")[1]
-	b, err := ioutil.ReadFile(file)
+func (vf *viewFlags) view() (expansion.View, error) {
+	return expansion.ParseView(vf.buckets, vf.definitions, vf.collapse, vf.focus, vf.hops)
+}
+
+func addOutputFlag(fs *flag.FlagSet) *string {
+	return fs.String("o", "", "output file, standard output if empty")
+}
+
+// withOutput runs write against the output file, or stdout if path is empty.
+func withOutput(stdout io.Writer, path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(stdout)
+	}
+	f, err := os.Create(path)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
-	var xdcrdefBlueprint XDCRDefBluePrint
-	switch format {
-	case "json":
-		err = json.Unmarshal(b, &xdcrdefBlueprint)
-	case "yaml":
-		err = yaml.Unmarshal(b, &xdcrdefBlueprint)
+	if err := write(f); err != nil {
+		f.Close()
+		return err
 	}
-	if err != nil {
-		fmt.Println(err)
-		return
+	return f.Close()
+}
+
+func renderCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	bf := addBlueprintFlags(fs)
+	format := fs.String("format", "dot", fmt.Sprintf("output graph format %v", render.RenderFormatNames()))
+	rflags := addRenderFlags(fs)
+	vflags := addViewFlags(fs)
+	out := addOutputFlag(fs)
+	return func(args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+		rf, err := render.GetRenderFormat(*format)
+		if err != nil {
+			return err
+		}
+		view, err := vflags.view()
+		if err != nil {
+			return err
+		}
+		rflags.options.Collapse = view.Collapse
+		renderer, err := rflags.renderer(rf.Renderer)
+		if err != nil {
+			return err
+		}
+		bp, err := bf.load()
+		if err != nil {
+			return err
+		}
+		if view.IsZero() {
+			return withOutput(stdout, *out, func(w io.Writer) error {
+				return render.RenderStream(renderer, w, bp.Datacenters, bp.EachXDCR)
+			})
+		}
+		dcs, xdcrs, err := view.Apply(bp)
+		if err != nil {
+			return err
+		}
+		return withOutput(stdout, *out, func(w io.Writer) error {
+			return renderer.Render(w, dcs, xdcrs)
+		})
 	}
+}
 
-	for _, xdcr := range xdcrdefBlueprint.XDCRDefs {
-		for _, x := range NewXDCR(xdcr, DCs) {
-			x.Dot(w)
+func serveCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	addr := fs.String("addr", ":1323", "address the server listens on")
+	return func(args []string) error {
+		if len(args) != 0 {
+			return errUsage
 		}
+		return server.Serve(*addr)
 	}
 }
 
-func FromFolder(folder, format string, DCs []Datacenter) {
-	var buf bytes.Buffer
-	err, _ := TopoFromFile(folder+"/couchbase."+format, DCs, &buf)
-	if err != nil {
-		return
+func validateCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	bf := addBlueprintFlags(fs)
+	policyFile := fs.String("policy", "", "policy file enforced on the expanded buckets and replications")
+	return func(args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+		bp, err := bf.load()
+		if err != nil {
+			return err
+		}
+		problems := expansion.Validate(bp)
+		errorCount := 0
+		for _, p := range problems {
+			fmt.Fprintln(stdout, p.String())
+			if ctx := p.Context(); ctx != "" {
+				fmt.Fprintln(stdout, ctx)
+			}
+			if !p.Warning {
+				errorCount++
+			}
+		}
+		if *policyFile != "" {
+			policies, err := expansion.PoliciesFromFile(*policyFile)
+			if err != nil {
+				return err
+			}
+			for _, v := range expansion.CheckPolicies(bp, policies) {
+				fmt.Fprintf(stdout, "violation: %s\n", v.String())
+				errorCount++
+			}
+		}
+		if errorCount > 0 {
+			return fmt.Errorf("%d error(s) found", errorCount)
+		}
+		return nil
 	}
-	XDCRFromFile(folder+"/XDCR."+format, DCs, &buf)
-	fmt.Printf("digraph { \n%s\n}\n", buf.String())
 }
 
-func FromDCFile(file string) {
-	b, err := ioutil.ReadFile(file)
-	if err != nil {
-		fmt.Println(err)
-		return
+// ExpandedBlueprint is the exported form of an expanded blueprint.
+type ExpandedBlueprint struct {
+	Datacenters []model.Datacenter `yaml:"datacenters" json:"datacenters"`
+	XDCRs       []model.XDCR       `yaml:"xdcrs" json:"xdcrs"`
+}
+
+func exportCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	bf := addBlueprintFlags(fs)
+	format := fs.String("format", "json", "export format [json|yaml]")
+	out := addOutputFlag(fs)
+	return func(args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+		bp, err := bf.load()
+		if err != nil {
+			return err
+		}
+		exp := ExpandedBlueprint{Datacenters: bp.Datacenters, XDCRs: bp.XDCRs()}
+		var b []byte
+		switch *format {
+		case "json":
+			b, err = json.MarshalIndent(exp, "", "\t")
+		case "yaml":
+			b, err = yaml.Marshal(exp)
+		default:
+			return fmt.Errorf("Invalid export format %q, expected json or yaml", *format)
+		}
+		if err != nil {
+			return err
+		}
+		return withOutput(stdout, *out, func(w io.Writer) error {
+			_, err := w.Write(b)
+			return err
+		})
 	}
-	var dcinjector DCInjector
-	err = yaml.Unmarshal(b, &dcinjector)
-	if err != nil {
-		fmt.Println(err)
-		return
+}
+
+func sampleCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	format := fs.String("format", "dot", fmt.Sprintf("output graph format %v", render.RenderFormatNames()))
+	out := addOutputFlag(fs)
+	return func(args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+		rf, err := render.GetRenderFormat(*format)
+		if err != nil {
+			return err
+		}
+		return withOutput(stdout, *out, func(w io.Writer) error {
+			if err := gen_sample1(w, rf.Renderer); err != nil {
+				return err
+			}
+			return gen_RBox1(w, rf.Renderer)
+		})
 	}
+}
 
-	datacenters := map[string]Datacenter{}
-	for _, dcs := range dcinjector.Topos {
-		for _, d := range dcs {
-			if _, ok := datacenters[d]; ok {
-				continue
+func explainCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	bf := addBlueprintFlags(fs)
+	format := fs.String("format", "text", "output format [text|json]")
+	return func(args []string) error {
+		if len(args) != 2 {
+			return errUsage
+		}
+		bp, err := bf.load()
+		if err != nil {
+			return err
+		}
+		e, err := expansion.Explain(bp, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		switch *format {
+		case "text":
+			e.WriteText(stdout)
+		case "json":
+			b, err := json.MarshalIndent(e, "", "\t")
+			if err != nil {
+				return err
 			}
-			datacenters[d] = NewDatacenter(d)
+			fmt.Fprintf(stdout, "%s\n", b)
+		default:
+			return fmt.Errorf("Invalid explain format %q, expected text or json", *format)
 		}
+		return nil
 	}
+}
 
-	var buf bytes.Buffer
+func queryCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	bf := addBlueprintFlags(fs)
+	format := fs.String("format", "table", "output format [table|json|csv]")
+	out := addOutputFlag(fs)
+	return func(args []string) error {
+		if len(args) > 1 {
+			return errUsage
+		}
+		query := ""
+		if len(args) == 1 {
+			query = args[0]
+		}
+		selector, err := model.ParseSelector(query)
+		if err != nil {
+			return err
+		}
+		bp, err := bf.load()
+		if err != nil {
+			return err
+		}
+		return withOutput(stdout, *out, func(w io.Writer) error {
+			return expansion.WriteQueryResults(w, *format, expansion.QueryBuckets(bp, selector))
+		})
+	}
+}
 
-	for f, dcs := range dcinjector.Topos {
-		for _, d := range dcs {
-			aDc := datacenters[d]
-			if err, s := TopoFromFile(f, []Datacenter{aDc}, &buf); err == nil {
-				datacenters[d] = s[0]
+func conflictsCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	bf := addBlueprintFlags(fs)
+	maxLoop := fs.Int("max-loop", 0, "maximum number of replications the mutations of a bucket of a cycle go through, at least, before coming back to it, unlimited if 0")
+	format := fs.String("format", "text", "output format [text|json]")
+	return func(args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+		bp, err := bf.load()
+		if err != nil {
+			return err
+		}
+		issues := expansion.CheckConflicts(bp, *maxLoop)
+		switch *format {
+		case "text":
+			for _, i := range issues {
+				fmt.Fprintln(stdout, i.String())
 			}
+		case "json":
+			b, err := json.MarshalIndent(issues, "", "\t")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "%s\n", b)
+		default:
+			return fmt.Errorf("invalid conflicts format %q, expected text or json", *format)
+		}
+		if len(issues) > 0 {
+			return fmt.Errorf("%d issue(s) found", len(issues))
+		}
+		return nil
+	}
+}
 
+func reportCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	bf := addBlueprintFlags(fs)
+	format := fs.String("format", "html", "report format [html|markdown]")
+	title := fs.String("title", "", "title of the report, the input path if empty")
+	policyFile := fs.String("policy", "", "policy file checked in the analysis section")
+	out := addOutputFlag(fs)
+	return func(args []string) error {
+		if len(args) != 0 {
+			return errUsage
 		}
+		if _, ok := report.Formats[*format]; !ok {
+			return fmt.Errorf("Invalid report format %q, expected html or markdown", *format)
+		}
+		bp, err := bf.load()
+		if err != nil {
+			return err
+		}
+		var policies []expansion.Policy
+		if *policyFile != "" {
+			if policies, err = expansion.PoliciesFromFile(*policyFile); err != nil {
+				return err
+			}
+		}
+		if *title == "" {
+			*title = bf.in
+		}
+		r, err := report.New(*title, bp, policies)
+		if err != nil {
+			return err
+		}
+		return withOutput(stdout, *out, func(w io.Writer) error {
+			return r.Write(w, *format)
+		})
 	}
+}
 
-	for f, dcs := range dcinjector.XDCRs {
-		DCS := []Datacenter{}
-		for _, d := range dcs {
-			DCS = append(DCS, datacenters[d])
+func physicalCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	bf := addBlueprintFlags(fs)
+	level := fs.String("level", string(model.ClusterBox), fmt.Sprintf("level the replications are aggregated at %v", expansion.PhysicalLevels))
+	format := fs.String("format", "table", fmt.Sprintf("output format [table|json|csv] or graph format %v", render.RenderFormatNames()))
+	rflags := addRenderFlags(fs)
+	out := addOutputFlag(fs)
+	return func(args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+		bp, err := bf.load()
+		if err != nil {
+			return err
+		}
+		xdcrs := bp.XDCRs()
+		switch *format {
+		case "table", "json", "csv":
+			links, err := expansion.NewPhysical(xdcrs).Links(model.BoxKind(*level))
+			if err != nil {
+				return err
+			}
+			return withOutput(stdout, *out, func(w io.Writer) error {
+				return expansion.WriteLinks(w, *format, links)
+			})
+		}
+		rf, err := render.GetRenderFormat(*format)
+		if err != nil {
+			return err
+		}
+		dcs, links, err := expansion.PhysicalGraph(bp.Datacenters, xdcrs, model.BoxKind(*level))
+		if err != nil {
+			return err
+		}
+		rflags.options.Collapse = model.BoxKind(*level)
+		renderer, err := rflags.renderer(rf.Renderer)
+		if err != nil {
+			return err
+		}
+		return withOutput(stdout, *out, func(w io.Writer) error {
+			return renderer.Render(w, dcs, links)
+		})
+	}
+}
+
+func schemaCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	out := addOutputFlag(fs)
+	return func(args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		s, err := expansion.Schema(args[0])
+		if err != nil {
+			return err
+		}
+		b, err := json.MarshalIndent(s, "", "\t")
+		if err != nil {
+			return err
+		}
+		return withOutput(stdout, *out, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "%s\n", b)
+			return err
+		})
+	}
+}
+
+func migrateCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	write := fs.Bool("w", false, "write the result to the files instead of the standard output")
+	return func(args []string) error {
+		if len(args) == 0 {
+			return errUsage
+		}
+		for _, file := range args {
+			b, err := expansion.MigrateFile(file)
+			if err != nil {
+				return err
+			}
+			if *write {
+				if err := ioutil.WriteFile(file, b, 0644); err != nil {
+					return err
+				}
+				continue
+			}
+			if len(args) > 1 {
+				fmt.Fprintf(stdout, "# %s\n", file)
+			}
+			stdout.Write(b)
+		}
+		return nil
+	}
+}
+
+func watchCommand(fs *flag.FlagSet, stdout io.Writer) func(args []string) error {
+	bf := addBlueprintFlags(fs)
+	addr := fs.String("addr", "localhost:1324", "address the page of the latest build is served on")
+	policyFile := fs.String("policy", "", "policy file enforced on the expanded buckets and replications")
+	interval := fs.Duration("interval", 500*time.Millisecond, "interval the files are checked for changes")
+	return func(args []string) error {
+		if len(args) != 0 || bf.in == "" {
+			return errUsage
+		}
+		if bf.in == expansion.Stdin {
+			return fmt.Errorf("Invalid input %q, the standard input can not be watched", bf.in)
+		}
+		if *interval <= 0 {
+			return fmt.Errorf("Invalid interval %s, it must be positive", *interval)
+		}
+		w := watch.New(bf.in, bf.load, *policyFile, bf.in)
+		listener, err := net.Listen("tcp", *addr)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "watching %s, the latest build is served on http://%s\n", bf.in, listener.Addr())
+		go w.Run(*interval, func(b watch.Build) { printBuild(stdout, b) }, nil)
+		return http.Serve(listener, w.Handler())
+	}
+}
+
+// printBuild reports the problems of a build of the watch command.
+func printBuild(stdout io.Writer, b watch.Build) {
+	fmt.Fprintf(stdout, "[%s] build %d: %d error(s)\n", b.Time.Format("15:04:05"), b.Generation, b.Errors())
+	for _, p := range b.Problems {
+		fmt.Fprintln(stdout, p.String())
+		if ctx := p.Context(); ctx != "" {
+			fmt.Fprintln(stdout, ctx)
+		}
+	}
+	if b.Report != nil {
+		for _, v := range b.Report.Violations {
+			fmt.Fprintf(stdout, "violation: %s\n", v.String())
 		}
-		XDCRFromFile(f, DCS, &buf)
 	}
-	fmt.Printf("digraph { \n%s\n}\n", buf.String())
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCLI(t *testing.T) {
+	dir := t.TempDir()
+	v1 := filepath.Join(dir, "XDCR.yaml")
+	if err := ioutil.WriteFile(v1, []byte("xdcrdefs:\n- rule: ring\n  source: {Role: Resa}\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := ioutil.WriteFile(invalid, []byte("apiVersion: couchbaseblueprint/v9\nkind: XDCR\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		code       int
+		wantStdout []string
+		wantStderr []string
+	}{
+		{"no command", nil, exitUsage, nil, []string{"Usage: couchbaseblueprint <command> [flags]", "  render "}},
+		{"help", []string{"help"}, exitOK, []string{"Usage: couchbaseblueprint <command> [flags]", "  schema "}, nil},
+		{"help of a command", []string{"help", "schema"}, exitOK, []string{"Usage: couchbaseblueprint schema [flags] <name>", "-o"}, nil},
+		{"flag help", []string{"schema", "-h"}, exitOK, nil, []string{"Usage: couchbaseblueprint schema [flags] <name>"}},
+		{"unknown command", []string{"unknown"}, exitUsage, nil, []string{`Unknown command "unknown"`, "Usage: couchbaseblueprint <command> [flags]"}},
+		{"unknown flag", []string{"render", "-unknown"}, exitUsage, nil, []string{"flag provided but not defined: -unknown", "Usage: couchbaseblueprint render [flags]"}},
+		{"schema", []string{"schema", "topology"}, exitOK, []string{`"title": "topology"`}, nil},
+		{"missing schema name", []string{"schema"}, exitUsage, nil, []string{"Usage: couchbaseblueprint schema [flags] <name>"}},
+		{"unknown schema", []string{"schema", "unknown"}, exitError, nil, []string{`couchbaseblueprint schema: Unknown schema "unknown"`}},
+		{"missing blueprint", []string{"validate"}, exitUsage, nil, []string{"Usage: couchbaseblueprint validate [flags]", "-in"}},
+		{"missing bucket", []string{"explain", "-in", dir, "DC1_CG_LH_C_0_A"}, exitUsage, nil, []string{"Usage: couchbaseblueprint explain [flags] <source bucket> <destination bucket>"}},
+		{"missing file", []string{"migrate"}, exitUsage, nil, []string{"Usage: couchbaseblueprint migrate [flags] <file>..."}},
+		{"migrate", []string{"migrate", v1}, exitOK, []string{"apiVersion: couchbaseblueprint/v2", "source: Role=Resa"}, nil},
+		{"unreadable file", []string{"migrate", filepath.Join(dir, "missing.yaml")}, exitError, nil, []string{"couchbaseblueprint migrate: ", "missing.yaml"}},
+		{"file error", []string{"migrate", invalid}, exitError, nil, []string{"couchbaseblueprint migrate: error: " + invalid + ":1:1: unknown apiVersion", "apiVersion: couchbaseblueprint/v9"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runCLI(tt.args, &stdout, &stderr); code != tt.code {
+				t.Errorf("runCLI(%q) = %d, want %d, stderr:\n%s", tt.args, code, tt.code, stderr.String())
+			}
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("runCLI(%q) stdout does not contain %q:\n%s", tt.args, want, stdout.String())
+				}
+			}
+			for _, want := range tt.wantStderr {
+				if !strings.Contains(stderr.String(), want) {
+					t.Errorf("runCLI(%q) stderr does not contain %q:\n%s", tt.args, want, stderr.String())
+				}
+			}
+			if len(tt.wantStdout) == 0 && stdout.Len() > 0 {
+				t.Errorf("runCLI(%q) stdout = %q, want nothing", tt.args, stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,387 @@
+// Package model holds the blueprint definitions and the expanded datacenter model.
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Labels map[string]string
+type Selector map[string]string
+
+// ParseSelector reads a selector written as comma separated key=value pairs.
+func ParseSelector(s string) (Selector, error) {
+	selector := Selector{}
+	if strings.TrimSpace(s) == "" {
+		return selector, nil
+	}
+	for _, term := range strings.Split(s, ",") {
+		kv := strings.SplitN(term, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("Invalid selector term %q, expected key=value", term)
+		}
+		selector[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return selector, nil
+}
+
+// UnmarshalYAML accepts a selector written as a map or, since couchbaseblueprint/v2, as a "k=v,k2=v2" string.
+func (s *Selector) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err == nil {
+		parsed, err := ParseSelector(str)
+		if err != nil {
+			return err
+		}
+		*s = parsed
+		return nil
+	}
+	var m map[string]string
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	*s = m
+	return nil
+}
+
+// UnmarshalJSON accepts a selector written as an object or as a "k=v,k2=v2" string. A null selector is left unset, as
+// a missing one.
+func (s *Selector) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		parsed, err := ParseSelector(str)
+		if err != nil {
+			return err
+		}
+		*s = parsed
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*s = m
+	return nil
+}
+
+// Header identifies the schema version and the kind of a blueprint file. Files without header are
+// couchbaseblueprint/v1 files.
+type Header struct {
+	APIVersion string `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty" json:"kind,omitempty"`
+}
+
+type LabelMatcher interface {
+	Match(s Selector) bool
+}
+
+type Datacenter struct {
+	Name string
+	// Labels are propagated to the cluster groups, clusters and buckets of the datacenter
+	Labels        Labels                 `json:",omitempty" yaml:",omitempty"`
+	Overrides     []ClusterGroupOverride `json:"-" yaml:"-"`
+	ClusterGroups []ClusterGroup
+}
+
+// DatacenterDef gives the labels of a datacenter and the overrides applied to the cluster group definitions expanded
+// in it.
+type DatacenterDef struct {
+	Name          string                 `yaml:"name" json:"name"`
+	Labels        Labels                 `yaml:"labels,omitempty" json:"labels,omitempty"`
+	ClusterGroups []ClusterGroupOverride `yaml:"clusterGroups,omitempty" json:"clusterGroups,omitempty"`
+}
+
+type DatacenterDefBluePrint struct {
+	Header      `yaml:",inline"`
+	Datacenters []DatacenterDef
+}
+
+// ClusterGroupOverride changes the cluster group definition having the same name. Empty fields keep the value of the
+// definition, labels are merged.
+type ClusterGroupOverride struct {
+	Name       string            `yaml:"name" json:"name"`
+	PeakTokens []string          `yaml:"peakTokens,omitempty" json:"peakTokens,omitempty"`
+	Labels     Labels            `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Clusters   []ClusterOverride `yaml:"clusters,omitempty" json:"clusters,omitempty"`
+}
+
+type ClusterOverride struct {
+	Name      string           `yaml:"name" json:"name"`
+	Instances []string         `yaml:"instances,omitempty" json:"instances,omitempty"`
+	Labels    Labels           `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Buckets   []BucketOverride `yaml:"buckets,omitempty" json:"buckets,omitempty"`
+}
+
+type BucketOverride struct {
+	Name              string `yaml:"name" json:"name"`
+	RamQuota          int    `yaml:"ramQuota,omitempty" json:"ramQuota,omitempty"`
+	CBReplicateNumber *int   `yaml:"cbReplicaNumber,omitempty" json:"cbReplicaNumber,omitempty"`
+	Labels            Labels `yaml:"labels,omitempty" json:"labels,omitempty"`
+	// ConflictResolution is kept when empty
+	ConflictResolution ConflictResolution `yaml:"conflictResolution,omitempty" json:"conflictResolution,omitempty"`
+}
+
+func (dc *Datacenter) AddClusterGroup(cg []ClusterGroup) {
+	dc.ClusterGroups = append(dc.ClusterGroups, cg...)
+}
+
+// AddClusterGroupDef expands the cluster group definition, after applying the override of the datacenter for it.
+func (dc *Datacenter) AddClusterGroupDef(cgdef ClusterGroupDef) {
+	for _, o := range dc.Overrides {
+		if o.Name == cgdef.Name {
+			cgdef = o.Apply(cgdef)
+		}
+	}
+	cg := NewClusterGroups(dc.Name, cgdef)
+	for i := range cg {
+		cg[i].addLabels(dc.Labels)
+	}
+	dc.ClusterGroups = append(dc.ClusterGroups, cg...)
+}
+
+func (dc *Datacenter) GetBuckets() []Bucket {
+	result := []Bucket{}
+	for _, cg := range dc.ClusterGroups {
+		for _, c := range cg.Clusters {
+			for _, b := range c.Buckets {
+				result = append(result, b)
+			}
+		}
+	}
+	return result
+}
+
+type ClusterGroupDefBluePrint struct {
+	Header        `yaml:",inline"`
+	ClusterGroups []ClusterGroupDef
+}
+
+type ClusterGroupDef struct {
+	Name        string       `yaml:"name" json:"name"`
+	PeakTokens  []string     `yaml:"peakTokens" json:"peakTokens"`
+	Labels      Labels       `yaml:"labels,omitempty" json:"labels,omitempty"`
+	ClusterDefs []ClusterDef `yaml:"clusters" json:"clusters"`
+}
+
+type ClusterGroup struct {
+	Name      string
+	PeakToken string
+	Labels    Labels
+	Clusters  []Cluster
+}
+
+type ClusterDef struct {
+	Name      string   `yaml:"name" json:"name"`
+	Instances []string `yaml:"instances" json:"instances"`
+	Labels    Labels   `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Buckets   []Bucket `yaml:"buckets" json:"buckets"`
+}
+
+type Cluster struct {
+	Name     string
+	Instance string
+	Labels   Labels
+	Buckets  []Bucket
+}
+
+type Bucket struct {
+	Name              string `yaml:"name" json:"name"`
+	RamQuota          int    `yaml:"ramQuota" json:"ramQuota"`
+	CBReplicateNumber int    `yaml:"cbReplicaNumber" json:"cbReplicaNumber"`
+	Labels            Labels `yaml:"labels,omitempty" json:"labels,omitempty"`
+	// ConflictResolution is seqno when empty, as in Couchbase
+	ConflictResolution ConflictResolution `yaml:"conflictResolution,omitempty" json:"conflictResolution,omitempty"`
+}
+
+// ConflictResolution is the way Couchbase picks the winner of concurrent mutations replicated by XDCR.
+type ConflictResolution string
+
+const (
+	SeqnoConflictResolution ConflictResolution = "seqno"
+	LWWConflictResolution   ConflictResolution = "lww"
+)
+
+// UnmarshalYAML reads the conflict resolution in lowercase, as Couchbase names them LWW and SeqNo.
+func (c *ConflictResolution) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err != nil {
+		return err
+	}
+	*c = ConflictResolution(strings.ToLower(str))
+	return nil
+}
+
+// UnmarshalJSON reads the conflict resolution in lowercase, as Couchbase names them LWW and SeqNo.
+func (c *ConflictResolution) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	*c = ConflictResolution(strings.ToLower(str))
+	return nil
+}
+
+// Resolution returns the conflict resolution of the bucket in lowercase, seqno by default.
+func (b *Bucket) Resolution() ConflictResolution {
+	if b.ConflictResolution == "" {
+		return SeqnoConflictResolution
+	}
+	return ConflictResolution(strings.ToLower(string(b.ConflictResolution)))
+}
+
+type XDCRRule string
+
+const (
+	UptreeRule XDCRRule = "uptree"
+	TreeRule   XDCRRule = "tree"
+	RingRule   XDCRRule = "ring"
+	ChainRule  XDCRRule = "chain"
+	CustomRule XDCRRule = "custom"
+	StarRule   XDCRRule = "star"
+	MeshRule   XDCRRule = "mesh"
+)
+
+// FanOut is the strategy used by the tree rules to assign a parent to each bucket of a level.
+type FanOut string
+
+const (
+	RoundRobinFanOut FanOut = "roundRobin"
+	BalancedFanOut   FanOut = "balanced"
+	AffinityFanOut   FanOut = "affinity"
+)
+
+// Pairing is the way the custom rule pairs the sources and the destinations of a group.
+type Pairing string
+
+const (
+	CartesianPairing Pairing = "cartesian"
+	ZipPairing       Pairing = "zip"
+	MatchPairing     Pairing = "match"
+)
+
+type XDCRDefBluePrint struct {
+	Header   `yaml:",inline"`
+	XDCRDefs []XDCRDef
+}
+
+type XDCRDef struct {
+	Rule               XDCRRule `yaml:"rule" json:"rule"`
+	Bidirectional      bool     `yaml:"bidirectional" json:"bidirectional"`
+	Source             Selector `yaml:"source" json:"source"`
+	SourceExclude      Selector `yaml:"sourceExclude,omitempty" json:"sourceExclude,omitempty"`
+	Destination        Selector `yaml:"destination,omitempty" json:"destination,omitempty"`
+	DestinationExclude Selector `yaml:"destinationExclude,omitempty" json:"destinationExclude,omitempty"`
+	GroupOn            []string `yaml:"groupOn,omitempty" json:"groupOn,omitempty"`
+	Args               []string `yaml:"args" json:"args"`
+	Color              string   `yaml:"color" json:"color"`
+	Hub                Selector `yaml:"hub,omitempty" json:"hub,omitempty"`
+	HubCount           int      `yaml:"hubCount,omitempty" json:"hubCount,omitempty"`
+	MaxPeers           int      `yaml:"maxPeers,omitempty" json:"maxPeers,omitempty"`
+	LevelOn            []string `yaml:"levelOn,omitempty" json:"levelOn,omitempty"`
+	FanOut             FanOut   `yaml:"fanOut,omitempty" json:"fanOut,omitempty"`
+	Affinity           []string `yaml:"affinity,omitempty" json:"affinity,omitempty"`
+	Pairing            Pairing  `yaml:"pairing,omitempty" json:"pairing,omitempty"`
+	PairOn             []string `yaml:"pairOn,omitempty" json:"pairOn,omitempty"`
+	// Priority weights the replications of the definition in the graphs, see EdgeStyle.PriorityWidth
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+}
+
+type XDCR struct {
+	Source      Bucket
+	Destination Bucket
+	Color       string
+	// Bidirectional is set when the definition of the replication also replicates the destination to the source
+	Bidirectional bool `yaml:"bidirectional,omitempty" json:",omitempty"`
+	Priority      int  `yaml:"priority,omitempty" json:",omitempty"`
+	// Label is written on the edge of the replication, the number of replications of an aggregated edge
+	Label string `yaml:"label,omitempty" json:",omitempty"`
+}
+
+func (b *Bucket) Match(s Selector) bool {
+	if s == nil {
+		return false
+	}
+	for k, v := range s {
+		vv, ok := b.Labels[k]
+		if !ok || vv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Bucket) GroupHash(groupOn []string) string {
+	f := "#"
+	if groupOn == nil {
+		return f
+	}
+
+	for _, k := range groupOn {
+		v := b.Labels[k]
+		f = f + k + ":" + v + "#"
+	}
+	return f
+}
+
+func (l Labels) Copy() Labels {
+	result := Labels{}
+	for k, v := range l {
+		result[k] = v
+	}
+	return result
+}
+
+// String returns the labels as sorted key=value pairs separated by commas.
+func (l Labels) String() string {
+	keys := []string{}
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	terms := []string{}
+	for _, k := range keys {
+		terms = append(terms, k+"="+l[k])
+	}
+	return strings.Join(terms, ",")
+}
+
+// FindBucket returns the bucket of the datacenters with the given path.
+func FindBucket(dcs []Datacenter, path string) (Bucket, bool) {
+	for _, dc := range dcs {
+		for _, b := range dc.GetBuckets() {
+			if b.Path() == path {
+				return b, true
+			}
+		}
+	}
+	return Bucket{}, false
+}
+
+type BucketByPath []Bucket
+
+func (a BucketByPath) Len() int           { return len(a) }
+func (a BucketByPath) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a BucketByPath) Less(i, j int) bool { return strings.Compare(a[i].Path(), a[j].Path()) < 0 }
+
+type DatacenterByName []Datacenter
+
+func (a DatacenterByName) Len() int           { return len(a) }
+func (a DatacenterByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a DatacenterByName) Less(i, j int) bool { return strings.Compare(a[i].Name, a[j].Name) < 0 }
+
+func (c *Cluster) Path() string {
+	return c.Labels["Datacenter"] + "_" + c.Labels["ClusterGroup"] + "_" + c.Name + "_" + c.Instance
+}
+
+func (cg *ClusterGroup) Path() string {
+	return cg.Labels["Datacenter"] + "_" + cg.Name + "_" + cg.PeakToken
+}
+
+func (b *Bucket) Path() string {
+	return b.Labels["Datacenter"] + "_" + b.Labels["ClusterGroup"] + "_" + b.Labels["Cluster"] + "_" + b.Name
+}
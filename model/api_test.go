@@ -0,0 +1,98 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestSelectorUnmarshal(t *testing.T) {
+	type doc struct {
+		Source Selector `yaml:"source" json:"source"`
+	}
+	tests := []struct {
+		name string
+		yaml string
+		json string
+		want Selector
+	}{
+		{"missing", `{}`, `{}`, nil},
+		{"null", `{source: null}`, `{"source": null}`, nil},
+		{"empty map", `{source: {}}`, `{"source": {}}`, Selector{}},
+		{"empty string", `{source: ""}`, `{"source": ""}`, Selector{}},
+		{"map", `{source: {Role: Resa}}`, `{"source": {"Role": "Resa"}}`, Selector{"Role": "Resa"}},
+		{"string", `{source: "Role=Resa, Level = 2"}`, `{"source": "Role=Resa, Level = 2"}`, Selector{"Role": "Resa", "Level": "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var y, j doc
+			if err := yaml.UnmarshalStrict([]byte(tt.yaml), &y); err != nil {
+				t.Fatalf("yaml: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.json), &j); err != nil {
+				t.Fatalf("json: %v", err)
+			}
+			if !reflect.DeepEqual(y.Source, tt.want) || (y.Source == nil) != (tt.want == nil) {
+				t.Errorf("yaml selector = %#v, want %#v", y.Source, tt.want)
+			}
+			if !reflect.DeepEqual(j.Source, tt.want) || (j.Source == nil) != (tt.want == nil) {
+				t.Errorf("json selector = %#v, want %#v", j.Source, tt.want)
+			}
+		})
+	}
+
+	var y struct{ Source Selector }
+	if err := yaml.Unmarshal([]byte(`{source: "Role"}`), &y); err == nil {
+		t.Errorf("yaml selector %q decoded, want an error", "Role")
+	}
+}
+
+func TestBucketMatch(t *testing.T) {
+	b := Bucket{Name: "B", Labels: Labels{"Role": "Resa", "Level": "2"}}
+	tests := []struct {
+		selector Selector
+		want     bool
+	}{
+		{nil, false},
+		{Selector{}, true},
+		{Selector{"Role": "Resa"}, true},
+		{Selector{"Role": "Resa", "Level": "1"}, false},
+		{Selector{"Rack": ""}, false},
+	}
+	for _, tt := range tests {
+		if got := b.Match(tt.selector); got != tt.want {
+			t.Errorf("Match(%#v) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestConflictResolutionUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		json string
+		want ConflictResolution
+	}{
+		{"missing", `{name: B}`, `{"name": "B"}`, ""},
+		{"lww", `{conflictResolution: lww}`, `{"conflictResolution": "lww"}`, LWWConflictResolution},
+		{"LWW", `{conflictResolution: LWW}`, `{"conflictResolution": "LWW"}`, LWWConflictResolution},
+		{"SeqNo", `{conflictResolution: SeqNo}`, `{"conflictResolution": "SeqNo"}`, SeqnoConflictResolution},
+		{"unknown", `{conflictResolution: Custom}`, `{"conflictResolution": "Custom"}`, "custom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var y, j Bucket
+			if err := yaml.UnmarshalStrict([]byte(tt.yaml), &y); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.json), &j); err != nil {
+				t.Fatal(err)
+			}
+			if y.ConflictResolution != tt.want || j.ConflictResolution != tt.want {
+				t.Errorf("ConflictResolution = %q (yaml), %q (json), want %q", y.ConflictResolution, j.ConflictResolution, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package model
+
+func NewDatacenter(name string) Datacenter {
+	return Datacenter{Name: name, ClusterGroups: []ClusterGroup{}}
+}
+
+func NewClusterGroups(dc string, def ClusterGroupDef) []ClusterGroup {
+	results := []ClusterGroup{}
+	if def.PeakTokens == nil {
+		return results
+	}
+
+	// the definition is shared by the datacenters, its labels are not changed
+	def.Labels = def.Labels.Copy()
+	def.Labels["Datacenter"] = dc
+
+	for _, p := range def.PeakTokens {
+		cg := ClusterGroup{Name: def.Name, PeakToken: p, Labels: def.Labels.Copy()}
+		cg.Clusters = []Cluster{}
+		lb := cg.Labels.Copy()
+		lb["ClusterGroup"] = cg.Name + "_" + cg.PeakToken
+		for _, cdef := range def.ClusterDefs {
+			cg.Clusters = append(cg.Clusters, NewClusters(lb, cdef)...)
+		}
+		results = append(results, cg)
+	}
+	return results
+}
+
+func NewClusters(lb Labels, def ClusterDef) []Cluster {
+	results := []Cluster{}
+	if def.Instances == nil {
+		return results
+	}
+
+	for _, i := range def.Instances {
+		if def.Labels == nil {
+			def.Labels = Labels{}
+		}
+
+		c := Cluster{Name: def.Name, Instance: i, Labels: def.Labels.Copy()}
+		c.Labels["Datacenter"], _ = lb["Datacenter"]
+		c.Labels["ClusterGroup"], _ = lb["ClusterGroup"]
+
+		for _, bdef := range def.Buckets {
+			bdef.Labels = bdef.Labels.Copy()
+			bdef.Labels["Cluster"] = c.Name + "_" + i
+			bdef.Labels["Datacenter"], _ = lb["Datacenter"]
+			bdef.Labels["ClusterGroup"], _ = lb["ClusterGroup"]
+
+			c.Buckets = append(c.Buckets, bdef)
+		}
+		results = append(results, c)
+	}
+	return results
+}
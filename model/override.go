@@ -0,0 +1,110 @@
+package model
+
+// NewDatacenterFromDef returns an empty datacenter carrying the labels and the overrides of def.
+func NewDatacenterFromDef(def DatacenterDef) Datacenter {
+	dc := NewDatacenter(def.Name)
+	dc.ApplyDef(def)
+	return dc
+}
+
+// ApplyDef merges the labels of def in the datacenter and adds its overrides. It must be called before the cluster
+// group definitions are added.
+func (dc *Datacenter) ApplyDef(def DatacenterDef) {
+	if len(def.Labels) > 0 {
+		if dc.Labels == nil {
+			dc.Labels = Labels{}
+		}
+		for k, v := range def.Labels {
+			dc.Labels[k] = v
+		}
+	}
+	dc.Overrides = append(dc.Overrides, def.ClusterGroups...)
+}
+
+// Apply returns a copy of the cluster group definition changed by the override. The definition itself is left
+// untouched as it is shared by all the datacenters.
+func (o ClusterGroupOverride) Apply(def ClusterGroupDef) ClusterGroupDef {
+	if o.PeakTokens != nil {
+		def.PeakTokens = o.PeakTokens
+	}
+	def.Labels = mergeLabels(def.Labels, o.Labels)
+
+	clusters := make([]ClusterDef, len(def.ClusterDefs))
+	copy(clusters, def.ClusterDefs)
+	for _, co := range o.Clusters {
+		for i := range clusters {
+			if clusters[i].Name == co.Name {
+				clusters[i] = co.Apply(clusters[i])
+			}
+		}
+	}
+	def.ClusterDefs = clusters
+	return def
+}
+
+func (o ClusterOverride) Apply(def ClusterDef) ClusterDef {
+	if o.Instances != nil {
+		def.Instances = o.Instances
+	}
+	def.Labels = mergeLabels(def.Labels, o.Labels)
+
+	buckets := make([]Bucket, len(def.Buckets))
+	copy(buckets, def.Buckets)
+	for _, bo := range o.Buckets {
+		for i := range buckets {
+			if buckets[i].Name == bo.Name {
+				buckets[i] = bo.Apply(buckets[i])
+			}
+		}
+	}
+	def.Buckets = buckets
+	return def
+}
+
+func (o BucketOverride) Apply(b Bucket) Bucket {
+	if o.RamQuota > 0 {
+		b.RamQuota = o.RamQuota
+	}
+	if o.CBReplicateNumber != nil {
+		b.CBReplicateNumber = *o.CBReplicateNumber
+	}
+	if o.ConflictResolution != "" {
+		b.ConflictResolution = o.ConflictResolution
+	}
+	b.Labels = mergeLabels(b.Labels, o.Labels)
+	return b
+}
+
+// mergeLabels returns a copy of labels with the values of overrides.
+func mergeLabels(labels, overrides Labels) Labels {
+	if len(overrides) == 0 {
+		return labels
+	}
+	result := labels.Copy()
+	for k, v := range overrides {
+		result[k] = v
+	}
+	return result
+}
+
+// addLabels sets the datacenter labels on the cluster group, its clusters and buckets, without replacing the labels
+// they already have.
+func (cg *ClusterGroup) addLabels(labels Labels) {
+	if len(labels) == 0 {
+		return
+	}
+	add := func(to Labels) {
+		for k, v := range labels {
+			if _, ok := to[k]; !ok {
+				to[k] = v
+			}
+		}
+	}
+	add(cg.Labels)
+	for i := range cg.Clusters {
+		add(cg.Clusters[i].Labels)
+		for j := range cg.Clusters[i].Buckets {
+			add(cg.Clusters[i].Buckets[j].Labels)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testClusterGroupDef() ClusterGroupDef {
+	return ClusterGroupDef{
+		Name:       "CG",
+		PeakTokens: []string{"LH"},
+		Labels:     Labels{"Env": "cg"},
+		ClusterDefs: []ClusterDef{{
+			Name:      "C",
+			Instances: []string{"0"},
+			Labels:    Labels{"Env": "c"},
+			Buckets:   []Bucket{{Name: "B", RamQuota: 256, CBReplicateNumber: 1, Labels: Labels{"Role": "Resa", "Env": "b"}}},
+		}},
+	}
+}
+
+func TestOverrideApply(t *testing.T) {
+	two := 2
+	tests := []struct {
+		name     string
+		override ClusterGroupOverride
+		// want changes the definition as the override should
+		want func(def *ClusterGroupDef)
+	}{
+		{"empty", ClusterGroupOverride{Name: "CG"}, func(def *ClusterGroupDef) {}},
+		{
+			"peakTokens",
+			ClusterGroupOverride{Name: "CG", PeakTokens: []string{"AF", "KL"}},
+			func(def *ClusterGroupDef) { def.PeakTokens = []string{"AF", "KL"} },
+		},
+		{
+			"instances",
+			ClusterGroupOverride{Name: "CG", Clusters: []ClusterOverride{{Name: "C", Instances: []string{"1", "2"}}}},
+			func(def *ClusterGroupDef) { def.ClusterDefs[0].Instances = []string{"1", "2"} },
+		},
+		{
+			"unknown cluster",
+			ClusterGroupOverride{Name: "CG", Clusters: []ClusterOverride{{Name: "X", Instances: []string{"1"}}}},
+			func(def *ClusterGroupDef) {},
+		},
+		{
+			"bucket",
+			ClusterGroupOverride{Name: "CG", Clusters: []ClusterOverride{{Name: "C", Buckets: []BucketOverride{
+				{Name: "B", RamQuota: 512, CBReplicateNumber: &two, ConflictResolution: LWWConflictResolution},
+			}}}},
+			func(def *ClusterGroupDef) {
+				b := &def.ClusterDefs[0].Buckets[0]
+				b.RamQuota, b.CBReplicateNumber, b.ConflictResolution = 512, 2, LWWConflictResolution
+			},
+		},
+		{
+			"labels",
+			ClusterGroupOverride{Name: "CG", Labels: Labels{"Env": "o", "Zone": "z"}, Clusters: []ClusterOverride{{Name: "C", Labels: Labels{"Env": "oc"}, Buckets: []BucketOverride{
+				{Name: "B", Labels: Labels{"Role": "Stat"}},
+			}}}},
+			func(def *ClusterGroupDef) {
+				def.Labels = Labels{"Env": "o", "Zone": "z"}
+				def.ClusterDefs[0].Labels = Labels{"Env": "oc"}
+				def.ClusterDefs[0].Buckets[0].Labels = Labels{"Role": "Stat", "Env": "b"}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := testClusterGroupDef()
+			want := testClusterGroupDef()
+			tt.want(&want)
+			if got := tt.override.Apply(def); !reflect.DeepEqual(got, want) {
+				t.Errorf("Apply() = %+v, want %+v", got, want)
+			}
+			if !reflect.DeepEqual(def, testClusterGroupDef()) {
+				t.Errorf("Apply() changes the definition to %+v", def)
+			}
+		})
+	}
+}
+
+// TestAddClusterGroupDefLabels checks the precedence of the labels: the built-in ones, then the ones of the overrides,
+// then the ones of the definitions, then the ones of the datacenter.
+func TestAddClusterGroupDefLabels(t *testing.T) {
+	dc := NewDatacenterFromDef(DatacenterDef{
+		Name:   "DC1",
+		Labels: Labels{"Env": "dc", "Zone": "dc", "Level": "1", "Datacenter": "other"},
+		ClusterGroups: []ClusterGroupOverride{{
+			Name:     "CG",
+			Labels:   Labels{"Zone": "o"},
+			Clusters: []ClusterOverride{{Name: "C", Buckets: []BucketOverride{{Name: "B", Labels: Labels{"Role": "Stat", "Cluster": "other"}}}}},
+		}},
+	})
+	dc.AddClusterGroupDef(testClusterGroupDef())
+
+	cg := dc.ClusterGroups[0]
+	if want := (Labels{"Datacenter": "DC1", "Env": "cg", "Zone": "o", "Level": "1"}); !reflect.DeepEqual(cg.Labels, want) {
+		t.Errorf("cluster group labels = %v, want %v", cg.Labels, want)
+	}
+	c := cg.Clusters[0]
+	if want := (Labels{"Datacenter": "DC1", "ClusterGroup": "CG_LH", "Env": "c", "Zone": "dc", "Level": "1"}); !reflect.DeepEqual(c.Labels, want) {
+		t.Errorf("cluster labels = %v, want %v", c.Labels, want)
+	}
+	b := c.Buckets[0]
+	if want := (Labels{"Datacenter": "DC1", "ClusterGroup": "CG_LH", "Cluster": "C_0", "Role": "Stat", "Env": "b", "Zone": "dc", "Level": "1"}); !reflect.DeepEqual(b.Labels, want) {
+		t.Errorf("bucket labels = %v, want %v", b.Labels, want)
+	}
+}
+
+// TestAddClusterGroupDefShared checks that the override of a datacenter does not leak in the other datacenters sharing
+// the cluster group definition.
+func TestAddClusterGroupDefShared(t *testing.T) {
+	def := testClusterGroupDef()
+	dc1 := NewDatacenterFromDef(DatacenterDef{
+		Name: "DC1",
+		ClusterGroups: []ClusterGroupOverride{{
+			Name:       "CG",
+			PeakTokens: []string{"AF"},
+			Labels:     Labels{"Env": "o"},
+			Clusters: []ClusterOverride{{Name: "C", Instances: []string{"1"}, Labels: Labels{"Env": "o"}, Buckets: []BucketOverride{
+				{Name: "B", RamQuota: 512, Labels: Labels{"Role": "Stat"}},
+			}}},
+		}},
+	})
+	dc2 := NewDatacenterFromDef(DatacenterDef{Name: "DC2"})
+	dc1.AddClusterGroupDef(def)
+	dc2.AddClusterGroupDef(def)
+
+	if !reflect.DeepEqual(def, testClusterGroupDef()) {
+		t.Errorf("AddClusterGroupDef() changes the definition to %+v", def)
+	}
+	cg1, cg2 := dc1.ClusterGroups[0], dc2.ClusterGroups[0]
+	if cg1.PeakToken != "AF" || cg2.PeakToken != "LH" {
+		t.Errorf("peak tokens = %s and %s, want AF and LH", cg1.PeakToken, cg2.PeakToken)
+	}
+	if cg1.Clusters[0].Instance != "1" || cg2.Clusters[0].Instance != "0" {
+		t.Errorf("instances = %s and %s, want 1 and 0", cg1.Clusters[0].Instance, cg2.Clusters[0].Instance)
+	}
+	if cg2.Labels["Env"] != "cg" || cg2.Clusters[0].Labels["Env"] != "c" {
+		t.Errorf("DC2 labels = %v and %v, want the ones of the definition", cg2.Labels, cg2.Clusters[0].Labels)
+	}
+	b1, b2 := cg1.Clusters[0].Buckets[0], cg2.Clusters[0].Buckets[0]
+	if b1.RamQuota != 512 || b2.RamQuota != 256 {
+		t.Errorf("ram quotas = %d and %d, want 512 and 256", b1.RamQuota, b2.RamQuota)
+	}
+	if b1.Labels["Role"] != "Stat" || b2.Labels["Role"] != "Resa" {
+		t.Errorf("roles = %s and %s, want Stat and Resa", b1.Labels["Role"], b2.Labels["Role"])
+	}
+	if b2.Labels["Datacenter"] != "DC2" {
+		t.Errorf("DC2 bucket labels = %v", b2.Labels)
+	}
+}
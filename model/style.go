@@ -0,0 +1,109 @@
+package model
+
+// BoxKind is the kind of box a ClusterStyle applies to.
+type BoxKind string
+
+const (
+	DatacenterBox   BoxKind = "datacenter"
+	ClusterGroupBox BoxKind = "clustergroup"
+	ClusterBox      BoxKind = "cluster"
+)
+
+// StyleBluePrint maps label selectors to the style of the graph elements. When several styles match an element their
+// attributes are merged in order, the later ones winning.
+type StyleBluePrint struct {
+	Header   `yaml:",inline"`
+	Nodes    []NodeStyle    `yaml:"nodes,omitempty" json:"nodes,omitempty"`
+	Clusters []ClusterStyle `yaml:"clusters,omitempty" json:"clusters,omitempty"`
+	Edges    EdgeStyle      `yaml:"edges,omitempty" json:"edges,omitempty"`
+}
+
+// NodeStyle is the style of the buckets matching Selector.
+type NodeStyle struct {
+	Selector Selector `yaml:"selector" json:"selector"`
+	Shape    string   `yaml:"shape,omitempty" json:"shape,omitempty"`
+	Color    string   `yaml:"color,omitempty" json:"color,omitempty"`
+	Fill     string   `yaml:"fill,omitempty" json:"fill,omitempty"`
+}
+
+// ClusterStyle is the style of the boxes of kind Box whose labels match Selector. The labels of a box are the ones of
+// its datacenter, cluster group or cluster, with its Datacenter, ClusterGroup or Cluster name as its buckets have.
+type ClusterStyle struct {
+	Box      BoxKind  `yaml:"box" json:"box"`
+	Selector Selector `yaml:"selector" json:"selector"`
+	Color    string   `yaml:"color,omitempty" json:"color,omitempty"`
+	Fill     string   `yaml:"fill,omitempty" json:"fill,omitempty"`
+	// Style is the line style of the box: solid, dashed, dotted or bold
+	Style string `yaml:"style,omitempty" json:"style,omitempty"`
+}
+
+// EdgeStyle is the style of the replications.
+type EdgeStyle struct {
+	// Bidirectional is the line style of the replications going both ways: dashed, dotted or bold
+	Bidirectional string `yaml:"bidirectional,omitempty" json:"bidirectional,omitempty"`
+	// PriorityWidth is the line width added for each priority point of the definition of a replication
+	PriorityWidth float64 `yaml:"priorityWidth,omitempty" json:"priorityWidth,omitempty"`
+}
+
+// Node returns the merged style of the node styles matching the bucket.
+func (s *StyleBluePrint) Node(b *Bucket) NodeStyle {
+	result := NodeStyle{}
+	if s == nil {
+		return result
+	}
+	for _, n := range s.Nodes {
+		if !b.Match(n.Selector) {
+			continue
+		}
+		result.Shape = override(result.Shape, n.Shape)
+		result.Color = override(result.Color, n.Color)
+		result.Fill = override(result.Fill, n.Fill)
+	}
+	return result
+}
+
+// Box returns the merged style of the cluster styles of the kind matching the labels.
+func (s *StyleBluePrint) Box(kind BoxKind, labels Labels) ClusterStyle {
+	result := ClusterStyle{Box: kind}
+	if s == nil {
+		return result
+	}
+	b := Bucket{Labels: labels}
+	for _, c := range s.Clusters {
+		if c.Box != kind || !b.Match(c.Selector) {
+			continue
+		}
+		result.Color = override(result.Color, c.Color)
+		result.Fill = override(result.Fill, c.Fill)
+		result.Style = override(result.Style, c.Style)
+	}
+	return result
+}
+
+// BoxLabels returns the labels the datacenter box styles are matched against.
+func (dc *Datacenter) BoxLabels() Labels {
+	l := dc.Labels.Copy()
+	l["Datacenter"] = dc.Name
+	return l
+}
+
+// BoxLabels returns the labels the cluster group box styles are matched against.
+func (cg *ClusterGroup) BoxLabels() Labels {
+	l := cg.Labels.Copy()
+	l["ClusterGroup"] = cg.Name + "_" + cg.PeakToken
+	return l
+}
+
+// BoxLabels returns the labels the cluster box styles are matched against.
+func (c *Cluster) BoxLabels() Labels {
+	l := c.Labels.Copy()
+	l["Cluster"] = c.Name + "_" + c.Instance
+	return l
+}
+
+func override(value, with string) string {
+	if with != "" {
+		return with
+	}
+	return value
+}
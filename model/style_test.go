@@ -0,0 +1,64 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStyleNode(t *testing.T) {
+	style := &StyleBluePrint{Nodes: []NodeStyle{
+		{Selector: Selector{"Role": "Resa"}, Shape: "box", Color: "blue"},
+		{Selector: Selector{"Role": "Resa", "Level": "1"}, Color: "red", Fill: "pink"},
+		{Selector: Selector{}, Fill: "white"},
+	}}
+	tests := []struct {
+		name   string
+		style  *StyleBluePrint
+		labels Labels
+		want   NodeStyle
+	}{
+		{"no style", nil, Labels{"Role": "Resa"}, NodeStyle{}},
+		{"default", style, Labels{"Role": "Stat"}, NodeStyle{Fill: "white"}},
+		{"one", style, Labels{"Role": "Resa"}, NodeStyle{Shape: "box", Color: "blue", Fill: "white"}},
+		// the later styles win, but do not reset the attributes they leave empty
+		{"merged", style, Labels{"Role": "Resa", "Level": "1"}, NodeStyle{Shape: "box", Color: "red", Fill: "white"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Bucket{Labels: tt.labels}
+			got := tt.style.Node(&b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Node() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStyleBox(t *testing.T) {
+	style := &StyleBluePrint{Clusters: []ClusterStyle{
+		{Box: DatacenterBox, Selector: Selector{"Level": "1"}, Color: "red"},
+		{Box: ClusterBox, Selector: Selector{"Level": "1"}, Fill: "grey", Style: "dashed"},
+		{Box: ClusterBox, Selector: Selector{"Cluster": "C_0"}, Style: "bold"},
+	}}
+	tests := []struct {
+		name   string
+		style  *StyleBluePrint
+		kind   BoxKind
+		labels Labels
+		want   ClusterStyle
+	}{
+		{"no style", nil, ClusterBox, Labels{"Level": "1"}, ClusterStyle{Box: ClusterBox}},
+		{"no match", style, ClusterBox, Labels{"Level": "2"}, ClusterStyle{Box: ClusterBox}},
+		{"other kind", style, ClusterGroupBox, Labels{"Level": "1"}, ClusterStyle{Box: ClusterGroupBox}},
+		{"datacenter", style, DatacenterBox, Labels{"Level": "1"}, ClusterStyle{Box: DatacenterBox, Color: "red"}},
+		{"merged", style, ClusterBox, Labels{"Level": "1", "Cluster": "C_0"}, ClusterStyle{Box: ClusterBox, Fill: "grey", Style: "bold"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.style.Box(tt.kind, tt.labels)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Box() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package render
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// CytoscapeRenderer writes the Cytoscape.js elements JSON, the hierarchy is expressed with compound nodes.
+// The styles of the options are carried by the data of the elements. The zero value renders with the DefaultOptions.
+type CytoscapeRenderer struct {
+	Options *Options
+}
+
+// WithOptions returns a Cytoscape renderer using the options.
+func (CytoscapeRenderer) WithOptions(o Options) Renderer {
+	return CytoscapeRenderer{Options: &o}
+}
+
+type CytoscapeGraph struct {
+	Elements CytoscapeElements `json:"elements"`
+}
+
+type CytoscapeElements struct {
+	Nodes []CytoscapeElement `json:"nodes"`
+	Edges []CytoscapeElement `json:"edges"`
+}
+
+type CytoscapeElement struct {
+	Data    CytoscapeData `json:"data"`
+	Classes string        `json:"classes,omitempty"`
+}
+
+type CytoscapeData struct {
+	ID     string       `json:"id"`
+	Label  string       `json:"label,omitempty"`
+	Parent string       `json:"parent,omitempty"`
+	Source string       `json:"source,omitempty"`
+	Target string       `json:"target,omitempty"`
+	Color  string       `json:"color,omitempty"`
+	Labels model.Labels `json:"labels,omitempty"`
+	// Shape, Fill, Line and Width are the styles of the element, if any
+	Shape string  `json:"shape,omitempty"`
+	Fill  string  `json:"fill,omitempty"`
+	Line  string  `json:"line,omitempty"`
+	Width float64 `json:"width,omitempty"`
+	// Definition is the index of the XDCRDef that produced an edge
+	Definition *int `json:"definition,omitempty"`
+}
+
+func (r CytoscapeRenderer) Render(w io.Writer, dcs []model.Datacenter, xdcrs []model.XDCR) error {
+	return r.RenderStream(w, dcs, Replications(xdcrs))
+}
+
+// RenderStream writes the same indented json as encoding a CytoscapeGraph, one element at a time.
+func (r CytoscapeRenderer) RenderStream(w io.Writer, dcs []model.Datacenter, xdcrs XDCRStream) error {
+	o := options(r.Options)
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	count := 0
+	element := func(e CytoscapeElement) error {
+		b, err := json.MarshalIndent(e, "\t\t\t", "\t")
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			bw.WriteString("[\n\t\t\t")
+		} else {
+			bw.WriteString(",\n\t\t\t")
+		}
+		bw.Write(b)
+		count++
+		return nil
+	}
+	end := func() {
+		if count == 0 {
+			bw.WriteString("[]")
+		} else {
+			bw.WriteString("\n\t\t]")
+		}
+		count = 0
+	}
+
+	bw.WriteString("{\n\t\"elements\": {\n\t\t\"nodes\": ")
+	g := newCytoscapeGraph(dcs, nil, o)
+	for _, n := range g.Elements.Nodes {
+		if err := element(n); err != nil {
+			return err
+		}
+	}
+	end()
+	bw.WriteString(",\n\t\t\"edges\": ")
+	i := 0
+	if err := xdcrs(func(x model.XDCR) error {
+		i++
+		return element(cytoscapeEdge(i-1, x, o))
+	}); err != nil {
+		return err
+	}
+	end()
+	bw.WriteString("\n\t}\n}")
+	return bw.Flush()
+}
+
+func NewCytoscapeGraph(dcs []model.Datacenter, xdcrs []model.XDCR) CytoscapeGraph {
+	return newCytoscapeGraph(dcs, xdcrs, DefaultOptions())
+}
+
+func newCytoscapeGraph(dcs []model.Datacenter, xdcrs []model.XDCR, o Options) CytoscapeGraph {
+	g := CytoscapeGraph{Elements: CytoscapeElements{Nodes: []CytoscapeElement{}, Edges: []CytoscapeElement{}}}
+	box := func(d CytoscapeData, st model.ClusterStyle) CytoscapeData {
+		d.Color, d.Fill, d.Line = st.Color, st.Fill, st.Style
+		return d
+	}
+	for i, dc := range dcs {
+		// the parent of the folded boxes is the closest drawn box
+		dcParent := ""
+		if o.drawn(model.DatacenterBox) {
+			st := o.box(model.DatacenterBox, dc.BoxLabels())
+			if st.Color == "" && len(o.ClusterColors) > 0 {
+				st.Color = o.ClusterColors[i%len(o.ClusterColors)]
+			}
+			g.addNode(box(CytoscapeData{ID: dc.Name, Label: dc.Name}, st), "datacenter")
+			dcParent = dc.Name
+		}
+		for _, cg := range dc.ClusterGroups {
+			cgParent := dcParent
+			if o.drawn(model.ClusterGroupBox) {
+				g.addNode(box(CytoscapeData{ID: cg.Path(), Label: cg.Name + " " + cg.PeakToken, Parent: dcParent, Labels: cg.Labels}, o.box(model.ClusterGroupBox, cg.BoxLabels())), "clustergroup")
+				cgParent = cg.Path()
+			}
+			for _, c := range cg.Clusters {
+				cParent := cgParent
+				if o.drawn(model.ClusterBox) {
+					g.addNode(box(CytoscapeData{ID: c.Path(), Label: c.Name + " " + c.Instance, Parent: cgParent, Labels: c.Labels}, o.box(model.ClusterBox, c.BoxLabels())), "cluster")
+					cParent = c.Path()
+				}
+				for _, b := range c.Buckets {
+					st := o.node(&b)
+					g.addNode(CytoscapeData{ID: b.Path(), Label: b.Name, Parent: cParent, Labels: b.Labels, Shape: st.Shape, Color: st.Color, Fill: st.Fill}, "bucket")
+				}
+			}
+		}
+	}
+	for i, x := range xdcrs {
+		g.Elements.Edges = append(g.Elements.Edges, cytoscapeEdge(i, x, o))
+	}
+	return g
+}
+
+func cytoscapeEdge(i int, x model.XDCR, o Options) CytoscapeElement {
+	st := o.edge(&x)
+	return CytoscapeElement{
+		Data: CytoscapeData{ID: fmt.Sprintf("e%d", i), Source: x.Source.Path(), Target: x.Destination.Path(), Label: x.Label, Color: x.Color, Line: st.Line, Width: st.Width},
+	}
+}
+
+func (g *CytoscapeGraph) addNode(d CytoscapeData, class string) {
+	g.Elements.Nodes = append(g.Elements.Nodes, CytoscapeElement{Data: d, Classes: class})
+}
@@ -0,0 +1,177 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// DotRenderer writes graphviz DOT. The zero value renders with the DefaultOptions.
+type DotRenderer struct {
+	Options *Options
+}
+
+// WithOptions returns a DOT renderer using the options.
+func (DotRenderer) WithOptions(o Options) Renderer {
+	return DotRenderer{Options: &o}
+}
+
+func (r DotRenderer) Render(w io.Writer, dcs []model.Datacenter, xdcrs []model.XDCR) error {
+	return r.RenderStream(w, dcs, Replications(xdcrs))
+}
+
+func (r DotRenderer) RenderStream(w io.Writer, dcs []model.Datacenter, xdcrs XDCRStream) error {
+	o := options(r.Options)
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	ctx := &dotContext{w: bufio.NewWriter(w), options: o, ranks: map[string]string{}}
+
+	fmt.Fprintf(ctx.w, "digraph { \n")
+	if o.Direction != "" {
+		fmt.Fprintf(ctx.w, "rankdir=%s;\n", strings.ToUpper(o.Direction))
+	}
+	for i := range dcs {
+		ctx.datacenter(&dcs[i], i)
+	}
+	if err := xdcrs(func(x model.XDCR) error {
+		ctx.xdcr(&x)
+		return nil
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.w, "\n}\n")
+	return ctx.w.Flush()
+}
+
+// dotContext holds the state of one DOT render, so that renders do not share anything.
+type dotContext struct {
+	w       *bufio.Writer
+	options Options
+	// ranks maps the values of the RankBy label to the path of the first bucket having it
+	ranks map[string]string
+}
+
+func (ctx *dotContext) cluster(c *model.Cluster) {
+
+	drawn := ctx.options.drawn(model.ClusterBox)
+	if drawn {
+		fmt.Fprintf(ctx.w, "subgraph cluster_%s {\n", c.Path())
+		fmt.Fprintf(ctx.w, "label=\"%s %s\";\n", c.Name, c.Instance)
+		ctx.box(ctx.options.box(model.ClusterBox, c.BoxLabels()))
+	}
+
+	for _, b := range c.Buckets {
+		attributes := []string{"label=" + b.Name}
+		if ctx.options.ShowLabels {
+			attributes[0] = fmt.Sprintf("label=\"%s\\n%s\"", dotEscape(b.Name), dotEscape(b.Labels.String()))
+		}
+		st := ctx.options.node(&b)
+		if st.Shape != "" {
+			attributes = append(attributes, "shape="+st.Shape)
+		}
+		if st.Color != "" {
+			attributes = append(attributes, fmt.Sprintf("color=\"%s\"", dotEscape(st.Color)))
+		}
+		if st.Fill != "" {
+			attributes = append(attributes, "style=filled", fmt.Sprintf("fillcolor=\"%s\"", dotEscape(st.Fill)))
+		}
+		fmt.Fprintf(ctx.w, "%s[%s];\n", b.Path(), strings.Join(attributes, ", "))
+		if ctx.options.RankBy == "" {
+			continue
+		}
+		if l, ok := b.Labels[ctx.options.RankBy]; ok {
+			if other, found := ctx.ranks[l]; found {
+				fmt.Fprintf(ctx.w, "{rank=same; %s %s}\n", b.Path(), other)
+			} else {
+				ctx.ranks[l] = b.Path()
+			}
+		}
+	}
+	if drawn {
+		fmt.Fprintf(ctx.w, "}\n")
+	}
+}
+
+func (ctx *dotContext) clusterGroup(cg *model.ClusterGroup) {
+
+	drawn := ctx.options.drawn(model.ClusterGroupBox)
+	if drawn {
+		fmt.Fprintf(ctx.w, "subgraph cluster_%s {\n", cg.Path())
+		fmt.Fprintf(ctx.w, "label=\"%s %s\";\n", cg.Name, cg.PeakToken)
+		ctx.box(ctx.options.box(model.ClusterGroupBox, cg.BoxLabels()))
+	}
+
+	for _, c := range cg.Clusters {
+		ctx.cluster(&c)
+	}
+
+	if drawn {
+		fmt.Fprintf(ctx.w, "}\n")
+	}
+
+}
+
+// datacenter writes the i-th datacenter, its box taking the i-th of the cluster colors.
+func (ctx *dotContext) datacenter(dc *model.Datacenter, i int) {
+
+	drawn := ctx.options.drawn(model.DatacenterBox)
+	if drawn {
+		fmt.Fprintf(ctx.w, "subgraph cluster_%s {\n", dc.Name)
+		fmt.Fprintf(ctx.w, "label=\"%s\";\n", dc.Name)
+		if colors := ctx.options.ClusterColors; len(colors) > 0 {
+			fmt.Fprintf(ctx.w, "color=\"%s\";\n", dotEscape(colors[i%len(colors)]))
+		}
+		ctx.box(ctx.options.box(model.DatacenterBox, dc.BoxLabels()))
+	}
+
+	for _, cg := range dc.ClusterGroups {
+		ctx.clusterGroup(&cg)
+	}
+
+	if drawn {
+		fmt.Fprintf(ctx.w, "}\n")
+	}
+
+}
+
+// box writes the graph attributes of the style of a box.
+func (ctx *dotContext) box(st model.ClusterStyle) {
+	if st.Color != "" {
+		fmt.Fprintf(ctx.w, "color=\"%s\";\n", dotEscape(st.Color))
+	}
+	switch {
+	case st.Fill != "" && st.Style != "":
+		fmt.Fprintf(ctx.w, "style=\"filled,%s\";\nfillcolor=\"%s\";\n", dotEscape(st.Style), dotEscape(st.Fill))
+	case st.Fill != "":
+		fmt.Fprintf(ctx.w, "style=filled;\nfillcolor=\"%s\";\n", dotEscape(st.Fill))
+	case st.Style != "":
+		fmt.Fprintf(ctx.w, "style=\"%s\";\n", dotEscape(st.Style))
+	}
+}
+
+// xdcr writes the edge of a replication, in the default color when it has none.
+func (ctx *dotContext) xdcr(x *model.XDCR) {
+	attributes := []string{}
+	if x.Color != "" {
+		attributes = append(attributes, fmt.Sprintf("color=\"%s\"", dotEscape(x.Color)))
+	}
+	st := ctx.options.edge(x)
+	if st.Line != "" {
+		attributes = append(attributes, fmt.Sprintf("style=\"%s\"", dotEscape(st.Line)))
+	}
+	if st.Width > 0 {
+		attributes = append(attributes, fmt.Sprintf("penwidth=%g", st.Width))
+	}
+	if x.Label != "" {
+		attributes = append(attributes, fmt.Sprintf("label=\"%s\"", dotEscape(x.Label)))
+	}
+	fmt.Fprintf(ctx.w, "%s -> %s [%s];\n", x.Source.Path(), x.Destination.Path(), strings.Join(attributes, ", "))
+}
+
+func dotEscape(s string) string {
+	return strings.Replace(s, "\"", "\\\"", -1)
+}
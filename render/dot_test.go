@@ -0,0 +1,26 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func TestDotEdgeColor(t *testing.T) {
+	a := model.Bucket{Name: "A", Labels: model.Labels{"Datacenter": "DC1"}}
+	b := model.Bucket{Name: "B", Labels: model.Labels{"Datacenter": "DC1"}}
+	dc := model.Datacenter{Name: "DC1", ClusterGroups: []model.ClusterGroup{{Clusters: []model.Cluster{{Buckets: []model.Bucket{a, b}}}}}}
+	xdcrs := []model.XDCR{{Source: a, Destination: b, Color: "#ff0000"}, {Source: b, Destination: a}}
+
+	var out bytes.Buffer
+	if err := (DotRenderer{}).Render(&out, []model.Datacenter{dc}, xdcrs); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"DC1___A -> DC1___B [color=\"#ff0000\"];", "DC1___B -> DC1___A [];"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Render() does not write %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// GraphMLRenderer writes GraphML with the yEd extensions so that groups and edge colors are displayed by yEd.
+// The zero value renders with the DefaultOptions.
+type GraphMLRenderer struct {
+	Options *Options
+}
+
+// WithOptions returns a GraphML renderer using the options.
+func (GraphMLRenderer) WithOptions(o Options) Renderer {
+	return GraphMLRenderer{Options: &o}
+}
+
+func (r GraphMLRenderer) Render(w io.Writer, dcs []model.Datacenter, xdcrs []model.XDCR) error {
+	return r.RenderStream(w, dcs, Replications(xdcrs))
+}
+
+func (r GraphMLRenderer) RenderStream(w io.Writer, dcs []model.Datacenter, xdcrs XDCRStream) error {
+	o := options(r.Options)
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n")
+	fmt.Fprintf(bw, "<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\" xmlns:y=\"http://www.yworks.com/xml/graphml\">\n")
+	fmt.Fprintf(bw, "<key id=\"label\" for=\"node\" attr.name=\"label\" attr.type=\"string\"/>\n")
+	fmt.Fprintf(bw, "<key id=\"color\" for=\"edge\" attr.name=\"color\" attr.type=\"string\"/>\n")
+	fmt.Fprintf(bw, "<key id=\"ng\" for=\"node\" yfiles.type=\"nodegraphics\"/>\n")
+	fmt.Fprintf(bw, "<key id=\"eg\" for=\"edge\" yfiles.type=\"edgegraphics\"/>\n")
+	fmt.Fprintf(bw, "<graph id=\"G\" edgedefault=\"directed\">\n")
+
+	for i, dc := range dcs {
+		if o.drawn(model.DatacenterBox) {
+			st := o.box(model.DatacenterBox, dc.BoxLabels())
+			if st.Color == "" && len(o.ClusterColors) > 0 {
+				st.Color = o.ClusterColors[i%len(o.ClusterColors)]
+			}
+			graphMLOpenGroup(bw, dc.Name, dc.Name, st)
+		}
+		for _, cg := range dc.ClusterGroups {
+			if o.drawn(model.ClusterGroupBox) {
+				graphMLOpenGroup(bw, cg.Path(), cg.Name+" "+cg.PeakToken, o.box(model.ClusterGroupBox, cg.BoxLabels()))
+			}
+			for _, c := range cg.Clusters {
+				if o.drawn(model.ClusterBox) {
+					graphMLOpenGroup(bw, c.Path(), c.Name+" "+c.Instance, o.box(model.ClusterBox, c.BoxLabels()))
+				}
+				for _, b := range c.Buckets {
+					text := b.Name
+					if o.ShowLabels {
+						text += "\n" + b.Labels.String()
+					}
+					st := o.node(&b)
+					fmt.Fprintf(bw, "<node id=\"%s\"><data key=\"label\">%s</data>", xmlEscape(b.Path()), xmlEscape(text))
+					fmt.Fprintf(bw, "<data key=\"ng\"><y:ShapeNode>%s<y:Shape type=\"%s\"/><y:NodeLabel>%s</y:NodeLabel></y:ShapeNode></data></node>\n", graphMLFill(st.Fill, st.Color, ""), graphMLShape(st.Shape), xmlEscape(text))
+				}
+				if o.drawn(model.ClusterBox) {
+					graphMLCloseGroup(bw)
+				}
+			}
+			if o.drawn(model.ClusterGroupBox) {
+				graphMLCloseGroup(bw)
+			}
+		}
+		if o.drawn(model.DatacenterBox) {
+			graphMLCloseGroup(bw)
+		}
+	}
+
+	i := 0
+	if err := xdcrs(func(x model.XDCR) error {
+		fmt.Fprintf(bw, "<edge id=\"e%d\" source=\"%s\" target=\"%s\">", i, xmlEscape(x.Source.Path()), xmlEscape(x.Destination.Path()))
+		fmt.Fprintf(bw, "<data key=\"color\">%s</data>", xmlEscape(x.Color))
+		fmt.Fprintf(bw, "<data key=\"eg\"><y:PolyLineEdge><y:LineStyle color=\"%s\"%s/><y:Arrows source=\"none\" target=\"standard\"/>%s</y:PolyLineEdge></data></edge>\n", colorHex(x.Color), graphMLLine(o.edge(&x)), graphMLEdgeLabel(x.Label))
+		i++
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(bw, "</graph>\n</graphml>\n")
+	return bw.Flush()
+}
+
+func graphMLOpenGroup(w io.Writer, id, label string, st model.ClusterStyle) {
+	fmt.Fprintf(w, "<node id=\"%s\" yfiles.foldertype=\"group\"><data key=\"label\">%s</data>", xmlEscape(id), xmlEscape(label))
+	fmt.Fprintf(w, "<data key=\"ng\"><y:ProxyAutoBoundsNode><y:Realizers active=\"0\"><y:GroupNode>%s<y:NodeLabel>%s</y:NodeLabel></y:GroupNode></y:Realizers></y:ProxyAutoBoundsNode></data>\n", graphMLFill(st.Fill, st.Color, st.Style), xmlEscape(label))
+	fmt.Fprintf(w, "<graph id=\"%s:\" edgedefault=\"directed\">\n", xmlEscape(id))
+}
+
+func graphMLCloseGroup(w io.Writer) {
+	fmt.Fprintf(w, "</graph>\n</node>\n")
+}
+
+// graphMLFill returns the fill and border elements of a node or a group, empty if it is not styled.
+func graphMLFill(fill, color, line string) string {
+	s := ""
+	if h, ok := lookupColor(fill); ok {
+		s += fmt.Sprintf("<y:Fill color=\"%s\" transparent=\"false\"/>", h)
+	}
+	if color != "" || line != "" {
+		border := ""
+		if color != "" {
+			border += fmt.Sprintf(" color=\"%s\"", colorHex(color))
+		}
+		if t := graphMLLineType(line); t != "" {
+			border += fmt.Sprintf(" type=\"%s\"", t)
+		}
+		s += "<y:BorderStyle" + border + "/>"
+	}
+	return s
+}
+
+// graphMLLine returns the attributes of the line of a replication, empty if it is not styled.
+func graphMLLine(e edgeStyle) string {
+	s := ""
+	if t := graphMLLineType(e.Line); t != "" {
+		s += fmt.Sprintf(" type=\"%s\"", t)
+	}
+	if e.Width > 0 {
+		s += fmt.Sprintf(" width=\"%g\"", e.Width)
+	}
+	return s
+}
+
+func graphMLLineType(line string) string {
+	switch line {
+	case "dashed":
+		return "dashed"
+	case "dotted":
+		return "dotted"
+	}
+	return ""
+}
+
+func graphMLEdgeLabel(label string) string {
+	if label == "" {
+		return ""
+	}
+	return "<y:EdgeLabel>" + xmlEscape(label) + "</y:EdgeLabel>"
+}
+
+// graphMLShape returns the yEd shape for a graphviz shape name, the buckets are ellipses by default.
+func graphMLShape(shape string) string {
+	switch strings.ToLower(shape) {
+	case "box", "rect", "rectangle", "square":
+		return "rectangle"
+	case "diamond":
+		return "diamond"
+	case "hexagon":
+		return "hexagon"
+	case "octagon":
+		return "octagon"
+	case "triangle":
+		return "triangle"
+	}
+	return "ellipse"
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// yEd only understands hexadecimal colors, the XDCR definitions use graphviz color names.
+var namedColors = map[string]string{
+	"black":       "#000000",
+	"white":       "#FFFFFF",
+	"red":         "#FF0000",
+	"green":       "#008000",
+	"blue":        "#0000FF",
+	"yellow":      "#FFFF00",
+	"orange":      "#FFA500",
+	"purple":      "#800080",
+	"brown":       "#A52A2A",
+	"pink":        "#FFC0CB",
+	"gray":        "#808080",
+	"grey":        "#808080",
+	"cyan":        "#00FFFF",
+	"magenta":     "#FF00FF",
+	"lightblue":   "#ADD8E6",
+	"lightgreen":  "#90EE90",
+	"lightgrey":   "#D3D3D3",
+	"lightgray":   "#D3D3D3",
+	"lightyellow": "#FFFFE0",
+	"darkblue":    "#00008B",
+	"darkgreen":   "#006400",
+	"darkred":     "#8B0000",
+}
+
+func colorHex(color string) string {
+	if h, ok := lookupColor(color); ok {
+		return h
+	}
+	return "#000000"
+}
+
+// lookupColor returns the hexadecimal value of a color, false if it is unknown.
+func lookupColor(color string) (string, bool) {
+	if strings.HasPrefix(color, "#") {
+		return color, true
+	}
+	h, ok := namedColors[strings.ToLower(color)]
+	return h, ok
+}
@@ -0,0 +1,155 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// MermaidRenderer writes a mermaid flowchart. The zero value renders with the DefaultOptions.
+type MermaidRenderer struct {
+	Options *Options
+}
+
+// WithOptions returns a mermaid renderer using the options.
+func (MermaidRenderer) WithOptions(o Options) Renderer {
+	return MermaidRenderer{Options: &o}
+}
+
+func (r MermaidRenderer) Render(w io.Writer, dcs []model.Datacenter, xdcrs []model.XDCR) error {
+	return r.RenderStream(w, dcs, Replications(xdcrs))
+}
+
+func (r MermaidRenderer) RenderStream(w io.Writer, dcs []model.Datacenter, xdcrs XDCRStream) error {
+	o := options(r.Options)
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	direction := "TB"
+	if o.Direction != "" {
+		direction = strings.ToUpper(o.Direction)
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "flowchart %s\n", direction)
+
+	for i, dc := range dcs {
+		if o.drawn(model.DatacenterBox) {
+			fmt.Fprintf(bw, "subgraph %s [\"%s\"]\n", dc.Name, mermaidEscape(dc.Name))
+		}
+		for _, cg := range dc.ClusterGroups {
+			if o.drawn(model.ClusterGroupBox) {
+				fmt.Fprintf(bw, "subgraph %s [\"%s %s\"]\n", cg.Path(), mermaidEscape(cg.Name), mermaidEscape(cg.PeakToken))
+			}
+			for _, c := range cg.Clusters {
+				if o.drawn(model.ClusterBox) {
+					fmt.Fprintf(bw, "subgraph %s [\"%s %s\"]\n", c.Path(), mermaidEscape(c.Name), mermaidEscape(c.Instance))
+				}
+				for _, b := range c.Buckets {
+					text := mermaidEscape(b.Name)
+					if o.ShowLabels {
+						text += "<br/>" + mermaidEscape(b.Labels.String())
+					}
+					st := o.node(&b)
+					open, close := mermaidShape(st.Shape)
+					fmt.Fprintf(bw, "%s%s\"%s\"%s\n", b.Path(), open, text, close)
+					mermaidStyle(bw, b.Path(), st.Fill, st.Color, "")
+				}
+				if o.drawn(model.ClusterBox) {
+					fmt.Fprintf(bw, "end\n")
+					st := o.box(model.ClusterBox, c.BoxLabels())
+					mermaidStyle(bw, c.Path(), st.Fill, st.Color, st.Style)
+				}
+			}
+			if o.drawn(model.ClusterGroupBox) {
+				fmt.Fprintf(bw, "end\n")
+				st := o.box(model.ClusterGroupBox, cg.BoxLabels())
+				mermaidStyle(bw, cg.Path(), st.Fill, st.Color, st.Style)
+			}
+		}
+		if o.drawn(model.DatacenterBox) {
+			fmt.Fprintf(bw, "end\n")
+			st := o.box(model.DatacenterBox, dc.BoxLabels())
+			if st.Color == "" && len(o.ClusterColors) > 0 {
+				st.Color = o.ClusterColors[i%len(o.ClusterColors)]
+			}
+			mermaidStyle(bw, dc.Name, st.Fill, st.Color, st.Style)
+		}
+	}
+
+	// link styles are addressed by the position of the edge in the declaration order
+	i := 0
+	if err := xdcrs(func(x model.XDCR) error {
+		if x.Label != "" {
+			fmt.Fprintf(bw, "%s -->|\"%s\"| %s\n", x.Source.Path(), mermaidEscape(x.Label), x.Destination.Path())
+		} else {
+			fmt.Fprintf(bw, "%s --> %s\n", x.Source.Path(), x.Destination.Path())
+		}
+		properties := []string{}
+		if x.Color != "" {
+			properties = append(properties, "stroke:"+x.Color)
+		}
+		st := o.edge(&x)
+		properties = append(properties, mermaidLine(st.Line)...)
+		if st.Width > 0 {
+			properties = append(properties, fmt.Sprintf("stroke-width:%gpx", st.Width))
+		}
+		if len(properties) > 0 {
+			fmt.Fprintf(bw, "linkStyle %d %s\n", i, strings.Join(properties, ","))
+		}
+		i++
+		return nil
+	}); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// mermaidShape returns the delimiters of a node text for a graphviz shape name, the buckets are cylinders by default.
+func mermaidShape(shape string) (string, string) {
+	switch strings.ToLower(shape) {
+	case "box", "rect", "rectangle", "square":
+		return "[", "]"
+	case "ellipse", "oval", "circle":
+		return "((", "))"
+	case "diamond":
+		return "{", "}"
+	case "hexagon":
+		return "{{", "}}"
+	}
+	return "[(", ")]"
+}
+
+// mermaidLine returns the css properties of a line style.
+func mermaidLine(line string) []string {
+	switch line {
+	case "dashed":
+		return []string{"stroke-dasharray:5 5"}
+	case "dotted":
+		return []string{"stroke-dasharray:2 2"}
+	case "bold":
+		return []string{"stroke-width:3px"}
+	}
+	return nil
+}
+
+// mermaidStyle writes the style of a node or a subgraph, if any.
+func mermaidStyle(w io.Writer, id, fill, color, line string) {
+	properties := []string{}
+	if fill != "" {
+		properties = append(properties, "fill:"+fill)
+	}
+	if color != "" {
+		properties = append(properties, "stroke:"+color)
+	}
+	properties = append(properties, mermaidLine(line)...)
+	if len(properties) > 0 {
+		fmt.Fprintf(w, "style %s %s\n", id, strings.Join(properties, ","))
+	}
+}
+
+func mermaidEscape(s string) string {
+	return strings.Replace(s, "\"", "#quot;", -1)
+}
@@ -0,0 +1,149 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// PlantUMLRenderer writes a PlantUML deployment diagram. The zero value renders with the DefaultOptions.
+type PlantUMLRenderer struct {
+	Options *Options
+}
+
+// WithOptions returns a PlantUML renderer using the options.
+func (PlantUMLRenderer) WithOptions(o Options) Renderer {
+	return PlantUMLRenderer{Options: &o}
+}
+
+func (r PlantUMLRenderer) Render(w io.Writer, dcs []model.Datacenter, xdcrs []model.XDCR) error {
+	return r.RenderStream(w, dcs, Replications(xdcrs))
+}
+
+func (r PlantUMLRenderer) RenderStream(w io.Writer, dcs []model.Datacenter, xdcrs XDCRStream) error {
+	o := options(r.Options)
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "@startuml\n")
+	switch strings.ToUpper(o.Direction) {
+	case "LR", "RL":
+		fmt.Fprintf(bw, "left to right direction\n")
+	}
+
+	for i, dc := range dcs {
+		if o.drawn(model.DatacenterBox) {
+			st := o.box(model.DatacenterBox, dc.BoxLabels())
+			if st.Color == "" && len(o.ClusterColors) > 0 {
+				st.Color = o.ClusterColors[i%len(o.ClusterColors)]
+			}
+			fmt.Fprintf(bw, "rectangle \"%s\" as %s%s {\n", plantUMLEscape(dc.Name), dc.Name, plantUMLStyle(st.Fill, st.Color, st.Style))
+		}
+		for _, cg := range dc.ClusterGroups {
+			if o.drawn(model.ClusterGroupBox) {
+				st := o.box(model.ClusterGroupBox, cg.BoxLabels())
+				fmt.Fprintf(bw, "rectangle \"%s %s\" as %s%s {\n", plantUMLEscape(cg.Name), plantUMLEscape(cg.PeakToken), cg.Path(), plantUMLStyle(st.Fill, st.Color, st.Style))
+			}
+			for _, c := range cg.Clusters {
+				if o.drawn(model.ClusterBox) {
+					st := o.box(model.ClusterBox, c.BoxLabels())
+					fmt.Fprintf(bw, "node \"%s %s\" as %s%s {\n", plantUMLEscape(c.Name), plantUMLEscape(c.Instance), c.Path(), plantUMLStyle(st.Fill, st.Color, st.Style))
+				}
+				for _, b := range c.Buckets {
+					text := plantUMLEscape(b.Name)
+					if o.ShowLabels {
+						text += "\\n" + plantUMLEscape(b.Labels.String())
+					}
+					st := o.node(&b)
+					fmt.Fprintf(bw, "%s \"%s\" as %s%s\n", plantUMLElement(st.Shape), text, b.Path(), plantUMLStyle(st.Fill, st.Color, ""))
+				}
+				if o.drawn(model.ClusterBox) {
+					fmt.Fprintf(bw, "}\n")
+				}
+			}
+			if o.drawn(model.ClusterGroupBox) {
+				fmt.Fprintf(bw, "}\n")
+			}
+		}
+		if o.drawn(model.DatacenterBox) {
+			fmt.Fprintf(bw, "}\n")
+		}
+	}
+
+	if err := xdcrs(func(x model.XDCR) error {
+		attributes := []string{}
+		if x.Color != "" {
+			attributes = append(attributes, "#"+plantUMLColor(x.Color))
+		}
+		st := o.edge(&x)
+		if st.Line != "" {
+			attributes = append(attributes, st.Line)
+		}
+		if st.Width > 0 {
+			attributes = append(attributes, fmt.Sprintf("thickness=%g", st.Width))
+		}
+		arrow := "-->"
+		if len(attributes) > 0 {
+			arrow = "-[" + strings.Join(attributes, ",") + "]->"
+		}
+		if x.Label != "" {
+			fmt.Fprintf(bw, "%s %s %s : %s\n", x.Source.Path(), arrow, x.Destination.Path(), plantUMLEscape(x.Label))
+		} else {
+			fmt.Fprintf(bw, "%s %s %s\n", x.Source.Path(), arrow, x.Destination.Path())
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(bw, "@enduml\n")
+	return bw.Flush()
+}
+
+// plantUMLElement returns the deployment element drawn for a graphviz shape name, the buckets are databases by
+// default.
+func plantUMLElement(shape string) string {
+	switch strings.ToLower(shape) {
+	case "box", "rect", "rectangle", "square":
+		return "rectangle"
+	case "ellipse", "oval", "circle":
+		return "circle"
+	case "hexagon":
+		return "hexagon"
+	case "cylinder", "":
+		return "database"
+	}
+	return "database"
+}
+
+// plantUMLStyle returns the inline style of an element, empty if it has none.
+func plantUMLStyle(fill, color, line string) string {
+	properties := []string{}
+	if fill != "" {
+		properties = append(properties, plantUMLColor(fill))
+	}
+	if color != "" {
+		properties = append(properties, "line:"+plantUMLColor(color))
+	}
+	if line != "" {
+		properties = append(properties, "line."+line)
+	}
+	if len(properties) == 0 {
+		return ""
+	}
+	return " #" + strings.Join(properties, ";")
+}
+
+// plantUMLColor returns a color name, or a hexadecimal color without its leading #, the prefix of the colors in the
+// PlantUML styles.
+func plantUMLColor(color string) string {
+	return strings.TrimPrefix(color, "#")
+}
+
+func plantUMLEscape(s string) string {
+	return strings.Replace(s, "\"", "'", -1)
+}
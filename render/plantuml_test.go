@@ -0,0 +1,35 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func TestPlantUMLColors(t *testing.T) {
+	dc := model.Datacenter{Name: "DC1", ClusterGroups: []model.ClusterGroup{{Name: "CG", Labels: model.Labels{"Datacenter": "DC1"}, Clusters: []model.Cluster{{
+		Name:    "C",
+		Labels:  model.Labels{"Datacenter": "DC1", "ClusterGroup": "CG"},
+		Buckets: []model.Bucket{{Name: "A", Labels: model.Labels{"Datacenter": "DC1", "ClusterGroup": "CG", "Cluster": "C"}}, {Name: "B", Labels: model.Labels{"Datacenter": "DC1", "ClusterGroup": "CG", "Cluster": "C"}}},
+	}}}}}
+	a, b := dc.GetBuckets()[0], dc.GetBuckets()[1]
+	xdcrs := []model.XDCR{{Source: a, Destination: b, Color: "#ff0000"}, {Source: b, Destination: a, Color: "blue"}}
+	o := DefaultOptions()
+	o.ClusterColors = []string{"#00ff00"}
+
+	var out bytes.Buffer
+	if err := (PlantUMLRenderer{Options: &o}).Render(&out, []model.Datacenter{dc}, xdcrs); err != nil {
+		t.Fatal(err)
+	}
+	s := out.String()
+	if strings.Contains(s, "##") {
+		t.Errorf("Render() writes a color with two #:\n%s", s)
+	}
+	for _, want := range []string{"-[#ff0000]->", "-[#blue]->", "#line:00ff00"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("Render() does not write %q:\n%s", want, s)
+		}
+	}
+}
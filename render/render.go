@@ -0,0 +1,141 @@
+// Package render writes the expanded datacenters and their replications in graph formats.
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// Renderer writes the expanded datacenters and their replications in a graph format.
+type Renderer interface {
+	Render(w io.Writer, dcs []model.Datacenter, xdcrs []model.XDCR) error
+}
+
+// Options tune the graph written by a renderer, each renderer ignores the options its format can not express.
+type Options struct {
+	// RankBy is the label whose buckets having the same value are put on the same rank, none when empty
+	RankBy string
+	// Direction is the direction of the layout: TB, LR, BT or RL, the default of the format when empty
+	Direction string
+	// ShowLabels adds the labels of the buckets to their node
+	ShowLabels bool
+	// ClusterColors are the colors of the datacenter boxes, cycled over the datacenters, none when empty
+	ClusterColors []string
+	// Style maps label selectors to the style of the nodes, boxes and edges, the styles override ClusterColors
+	Style *model.StyleBluePrint
+	// Collapse is the level of the boxes folded into their single node by a view, their box is not drawn: cluster,
+	// clustergroup or datacenter, none when empty
+	Collapse model.BoxKind
+}
+
+// DefaultOptions returns the options of the zero value renderers: the buckets are ranked by Level.
+func DefaultOptions() Options {
+	return Options{RankBy: "Level"}
+}
+
+// Directions are the accepted layout directions.
+var Directions = []string{"TB", "LR", "BT", "RL"}
+
+// Validate checks the options.
+func (o Options) Validate() error {
+	switch o.Collapse {
+	case "", model.ClusterBox, model.ClusterGroupBox, model.DatacenterBox:
+	default:
+		return fmt.Errorf("Invalid collapse %q, expected %s, %s or %s", o.Collapse, model.ClusterBox, model.ClusterGroupBox, model.DatacenterBox)
+	}
+	if o.Direction == "" {
+		return nil
+	}
+	for _, d := range Directions {
+		if strings.EqualFold(d, o.Direction) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid direction %q, expected one of %v", o.Direction, Directions)
+}
+
+// Configurable is a Renderer accepting options.
+type Configurable interface {
+	Renderer
+	WithOptions(o Options) Renderer
+}
+
+// WithOptions returns r using the options, r itself when it does not accept options.
+func WithOptions(r Renderer, o Options) Renderer {
+	if c, ok := r.(Configurable); ok {
+		return c.WithOptions(o)
+	}
+	return r
+}
+
+// XDCRStream calls emit with each replication in order and stops at the first error emit returns.
+type XDCRStream func(emit func(model.XDCR) error) error
+
+// Replications returns the stream of a list of replications.
+func Replications(xdcrs []model.XDCR) XDCRStream {
+	return func(emit func(model.XDCR) error) error {
+		for _, x := range xdcrs {
+			if err := emit(x); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// StreamRenderer is a Renderer writing the replications as they are produced, without holding them all in memory.
+type StreamRenderer interface {
+	Renderer
+	RenderStream(w io.Writer, dcs []model.Datacenter, xdcrs XDCRStream) error
+}
+
+// RenderStream writes the graph with r, the replications are collected first when r does not stream.
+func RenderStream(r Renderer, w io.Writer, dcs []model.Datacenter, xdcrs XDCRStream) error {
+	if sr, ok := r.(StreamRenderer); ok {
+		return sr.RenderStream(w, dcs, xdcrs)
+	}
+	list := []model.XDCR{}
+	if err := xdcrs(func(x model.XDCR) error {
+		list = append(list, x)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return r.Render(w, dcs, list)
+}
+
+// RenderFormat describes a renderer and how its output is stored or served.
+type RenderFormat struct {
+	Renderer    Renderer
+	Extension   string
+	ContentType string
+}
+
+var RenderFormats = map[string]RenderFormat{
+	"dot":       {Renderer: DotRenderer{}, Extension: "dot", ContentType: "text/vnd.graphviz; charset=utf-8"},
+	"mermaid":   {Renderer: MermaidRenderer{}, Extension: "mmd", ContentType: "text/plain; charset=utf-8"},
+	"plantuml":  {Renderer: PlantUMLRenderer{}, Extension: "puml", ContentType: "text/plain; charset=utf-8"},
+	"graphml":   {Renderer: GraphMLRenderer{}, Extension: "graphml", ContentType: "application/xml; charset=utf-8"},
+	"cytoscape": {Renderer: CytoscapeRenderer{}, Extension: "json", ContentType: "application/json"},
+}
+
+func GetRenderFormat(name string) (RenderFormat, error) {
+	f, ok := RenderFormats[name]
+	if !ok {
+		return RenderFormat{}, fmt.Errorf("Unknown output format %q, expected one of %v", name, RenderFormatNames())
+	}
+	return f, nil
+}
+
+func RenderFormatNames() []string {
+	names := []string{}
+	for n := range RenderFormats {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,113 @@
+package render_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+	"github.com/dbenque/couchbaseblueprint/render"
+	"github.com/dbenque/couchbaseblueprint/rules"
+)
+
+// syntheticGraph returns dcCount datacenters of clusterCount clusters of bucketCount buckets, and a ring of each
+// bucket role inside each datacenter and over all the datacenters.
+func syntheticGraph(dcCount, clusterCount, bucketCount int) ([]model.Datacenter, []model.XDCR) {
+	instances := []string{}
+	for i := 0; i < clusterCount; i++ {
+		instances = append(instances, strconv.Itoa(i))
+	}
+	buckets := []model.Bucket{}
+	defs := []model.XDCRDef{}
+	for i := 0; i < bucketCount; i++ {
+		role := fmt.Sprintf("R%d", i)
+		buckets = append(buckets, model.Bucket{Name: fmt.Sprintf("B%d", i), RamQuota: 256, Labels: model.Labels{"Role": role}})
+		defs = append(defs,
+			model.XDCRDef{Rule: model.RingRule, Source: model.Selector{"Role": role}, GroupOn: []string{"Datacenter"}, Color: "green"},
+			model.XDCRDef{Rule: model.RingRule, Source: model.Selector{"Role": role, "Level": "1"}, Color: "#ff0000"},
+		)
+	}
+	cgdef := model.ClusterGroupDef{Name: "CG", PeakTokens: []string{"PK"}, ClusterDefs: []model.ClusterDef{{Name: "C", Instances: instances, Buckets: buckets}}}
+	dcs := []model.Datacenter{}
+	for i := 0; i < dcCount; i++ {
+		dc := model.NewDatacenterFromDef(model.DatacenterDef{Name: fmt.Sprintf("DC%d", i+1), Labels: model.Labels{"Level": strconv.Itoa(1 + i%3)}})
+		dc.AddClusterGroupDef(cgdef)
+		dcs = append(dcs, dc)
+	}
+	xdcrs := []model.XDCR{}
+	for _, l := range rules.NewXDCRs(defs, dcs) {
+		xdcrs = append(xdcrs, l...)
+	}
+	return dcs, xdcrs
+}
+
+func BenchmarkRender(b *testing.B) {
+	dcs, xdcrs := syntheticGraph(100, 10, 10)
+	for _, name := range render.RenderFormatNames() {
+		rf, _ := render.GetRenderFormat(name)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := render.RenderStream(rf.Renderer, ioutil.Discard, dcs, render.Replications(xdcrs)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestDotConcurrentRenders checks that DOT renders run in parallel write the same as when run alone, in particular that
+// the rank=same lines of one render do not leak into another. Run it with -race.
+func TestDotConcurrentRenders(t *testing.T) {
+	type job struct {
+		dcs   []model.Datacenter
+		xdcrs []model.XDCR
+		r     render.Renderer
+	}
+	jobs := []job{}
+	for i := 0; i < 16; i++ {
+		dcs, xdcrs := syntheticGraph(1+i%4, 1+i%3, 2+i%5)
+		o := render.DefaultOptions()
+		if i%2 == 1 {
+			o.RankBy = "Role"
+		}
+		jobs = append(jobs, job{dcs, xdcrs, render.DotRenderer{}.WithOptions(o)})
+	}
+	alone := make([]string, len(jobs))
+	for i, j := range jobs {
+		var out bytes.Buffer
+		if err := j.r.Render(&out, j.dcs, j.xdcrs); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), "rank=same") {
+			t.Fatalf("Render() of job %d writes no rank=same line:\n%s", i, out.String())
+		}
+		alone[i] = out.String()
+	}
+
+	parallel := make([]string, len(jobs))
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var out bytes.Buffer
+			errs[i] = jobs[i].r.Render(&out, jobs[i].dcs, jobs[i].xdcrs)
+			parallel[i] = out.String()
+		}(i)
+	}
+	wg.Wait()
+	for i := range jobs {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if parallel[i] != alone[i] {
+			t.Errorf("Render() of job %d in parallel = \n%s\nwant\n%s", i, parallel[i], alone[i])
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package render
+
+import "github.com/dbenque/couchbaseblueprint/model"
+
+// options returns the options of a renderer, the DefaultOptions when it has none.
+func options(o *Options) Options {
+	if o == nil {
+		return DefaultOptions()
+	}
+	return *o
+}
+
+// edgeStyle is the style of a replication resolved from the EdgeStyle of the options.
+type edgeStyle struct {
+	// Line is the line style: dashed, dotted or bold, solid when empty
+	Line string
+	// Width is the line width, the default of the format when zero
+	Width float64
+}
+
+// drawn tells if the boxes of the kind are drawn, the ones at or below the Collapse level are folded into a node.
+func (o Options) drawn(kind model.BoxKind) bool {
+	switch o.Collapse {
+	case model.ClusterBox:
+		return kind != model.ClusterBox
+	case model.ClusterGroupBox:
+		return kind != model.ClusterBox && kind != model.ClusterGroupBox
+	case model.DatacenterBox:
+		return false
+	}
+	return true
+}
+
+func (o Options) node(b *model.Bucket) model.NodeStyle {
+	return o.Style.Node(b)
+}
+
+func (o Options) box(kind model.BoxKind, labels model.Labels) model.ClusterStyle {
+	if o.Style == nil {
+		return model.ClusterStyle{Box: kind}
+	}
+	return o.Style.Box(kind, labels)
+}
+
+func (o Options) edge(x *model.XDCR) edgeStyle {
+	e := edgeStyle{}
+	if o.Style == nil {
+		return e
+	}
+	if x.Bidirectional {
+		e.Line = o.Style.Edges.Bidirectional
+	}
+	if x.Priority > 0 && o.Style.Edges.PriorityWidth > 0 {
+		e.Width = 1 + float64(x.Priority)*o.Style.Edges.PriorityWidth
+	}
+	return e
+}
@@ -0,0 +1,118 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// testStyledGraph returns the datacenter DC1 of the level 1 and DC2 of the level 2, each having the buckets A of the
+// role resa and B of the role stat, and the replications A->B of priority 2 in DC1 and A->B, B->A in DC2.
+func testStyledGraph() ([]model.Datacenter, []model.XDCR) {
+	cgdef := model.ClusterGroupDef{Name: "CG", PeakTokens: []string{"LH"}, ClusterDefs: []model.ClusterDef{{
+		Name:      "C",
+		Instances: []string{"0"},
+		Buckets: []model.Bucket{
+			{Name: "A", Labels: model.Labels{"Role": "resa"}},
+			{Name: "B", Labels: model.Labels{"Role": "stat"}},
+		},
+	}}}
+	dcs := []model.Datacenter{}
+	for _, level := range []string{"1", "2"} {
+		dc := model.NewDatacenterFromDef(model.DatacenterDef{Name: "DC" + level, Labels: model.Labels{"Level": level}})
+		dc.AddClusterGroupDef(cgdef)
+		dcs = append(dcs, dc)
+	}
+	b1, b2 := dcs[0].GetBuckets(), dcs[1].GetBuckets()
+	xdcrs := []model.XDCR{
+		{Source: b1[0], Destination: b1[1], Priority: 2},
+		{Source: b2[0], Destination: b2[1], Bidirectional: true},
+		{Source: b2[1], Destination: b2[0], Bidirectional: true},
+	}
+	return dcs, xdcrs
+}
+
+var testStyle = &model.StyleBluePrint{
+	Nodes: []model.NodeStyle{{Selector: model.Selector{"Role": "resa", "Level": "1"}, Shape: "box", Fill: "pink"}},
+	Clusters: []model.ClusterStyle{
+		{Box: model.DatacenterBox, Selector: model.Selector{"Level": "2"}, Fill: "grey"},
+		{Box: model.ClusterBox, Selector: model.Selector{"Level": "1"}, Style: "dashed"},
+	},
+	Edges: model.EdgeStyle{Bidirectional: "dotted", PriorityWidth: 1.5},
+}
+
+func TestStyles(t *testing.T) {
+	tests := []struct {
+		name     string
+		renderer interface{ WithOptions(Options) Renderer }
+		style    *model.StyleBluePrint
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "dot",
+			renderer: DotRenderer{},
+			style:    testStyle,
+			want: []string{
+				"DC1_CG_LH_C_0_A[label=A, shape=box, style=filled, fillcolor=\"pink\"];",
+				"DC1_CG_LH_C_0_B[label=B];",
+				"DC2_CG_LH_C_0_A[label=A];",
+				"label=\"DC2\";\ncolor=\"#FFFFFF\";\nstyle=filled;\nfillcolor=\"grey\";\n",
+				"label=\"C 0\";\nstyle=\"dashed\";\n",
+				"DC1_CG_LH_C_0_A -> DC1_CG_LH_C_0_B [penwidth=4];",
+				"DC2_CG_LH_C_0_A -> DC2_CG_LH_C_0_B [style=\"dotted\"];",
+			},
+		},
+		{
+			name:     "dot default",
+			renderer: DotRenderer{},
+			want:     []string{"DC1_CG_LH_C_0_A[label=A];", "DC1_CG_LH_C_0_A -> DC1_CG_LH_C_0_B [];"},
+			notWant:  []string{"fillcolor", "shape=", "style=", "penwidth"},
+		},
+		{
+			name:     "mermaid",
+			renderer: MermaidRenderer{},
+			style:    testStyle,
+			want: []string{
+				"DC1_CG_LH_C_0_A[\"A\"]\nstyle DC1_CG_LH_C_0_A fill:pink\n",
+				"DC1_CG_LH_C_0_B[(\"B\")]\n",
+				"DC2_CG_LH_C_0_A[(\"A\")]\n",
+				"style DC2 fill:grey",
+				"style DC1_CG_LH_C_0 stroke-dasharray:5 5\n",
+				"linkStyle 0 stroke-width:4px\n",
+				"linkStyle 1 stroke-dasharray:2 2\n",
+			},
+		},
+		{
+			name:     "mermaid default",
+			renderer: MermaidRenderer{},
+			want:     []string{"DC1_CG_LH_C_0_A[(\"A\")]\n"},
+			notWant:  []string{"fill:", "stroke-dasharray", "linkStyle"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOptions()
+			o.Style = tt.style
+			// the ClusterColors are the default color of the datacenters
+			o.ClusterColors = []string{"#FFFFFF"}
+			dcs, xdcrs := testStyledGraph()
+			var out bytes.Buffer
+			if err := tt.renderer.WithOptions(o).Render(&out, dcs, xdcrs); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Render() does not write %q:\n%s", want, out.String())
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out.String(), notWant) {
+					t.Errorf("Render() writes %q:\n%s", notWant, out.String())
+				}
+			}
+		})
+	}
+}
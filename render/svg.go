@@ -0,0 +1,31 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// GraphvizAvailable tells whether the dot command of graphviz is on the PATH.
+func GraphvizAvailable() bool {
+	_, err := exec.LookPath("dot")
+	return err == nil
+}
+
+// DotSVG lays out the dot graph with graphviz. The xml prolog is dropped so that the svg can be inlined in html.
+func DotSVG(dot []byte) (string, error) {
+	var out, outerr bytes.Buffer
+	cmd := exec.Command("dot", "-Tsvg")
+	cmd.Stdin = bytes.NewReader(dot)
+	cmd.Stdout = &out
+	cmd.Stderr = &outerr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("dot: %v: %s", err, outerr.String())
+	}
+	svg := out.String()
+	if i := strings.Index(svg, "<svg"); i > 0 {
+		svg = svg[i:]
+	}
+	return svg, nil
+}
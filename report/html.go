@@ -0,0 +1,91 @@
+package report
+
+import (
+	"html/template"
+	"io"
+)
+
+var htmlTemplate = template.Must(template.New("html").Funcs(funcs).Funcs(template.FuncMap{
+	"svg": func(s string) template.HTML { return template.HTML(s) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; font-size: 90%; }
+th { background: #eee; }
+.error { color: #a00; }
+.warning { color: #a60; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+
+<h2>Datacenters</h2>
+<table>
+<tr><th>Datacenter</th><th>Labels</th><th>Cluster groups</th><th>Clusters</th><th>Buckets</th><th>RAM quota</th></tr>
+{{range .Datacenters}}<tr><td>{{.Name}}</td><td>{{labels .Labels}}</td><td>{{.ClusterGroups}}</td><td>{{.Clusters}}</td><td>{{.Buckets}}</td><td>{{.RamQuota}}</td></tr>
+{{end}}</table>
+
+<h2>Clusters</h2>
+<table>
+<tr><th>Cluster</th><th>Instance</th><th>Labels</th><th>Buckets</th><th>RAM quota</th></tr>
+{{range .Clusters}}<tr><td>{{.Path}}</td><td>{{.Instance}}</td><td>{{labels .Labels}}</td><td>{{.Buckets}}</td><td>{{.RamQuota}}</td></tr>
+{{end}}</table>
+
+<h2>Buckets</h2>
+<table>
+<tr><th>Bucket</th><th>Name</th><th>RAM quota</th><th>Replicas</th><th>Inbound</th><th>Outbound</th><th>Labels</th></tr>
+{{range .Buckets}}<tr><td>{{.Path}}</td><td>{{.Name}}</td><td>{{.RamQuota}}</td><td>{{.CBReplicateNumber}}</td><td>{{.Inbound}}</td><td>{{.Outbound}}</td><td>{{labels .Labels}}</td></tr>
+{{end}}</table>
+
+<h2>Replication links</h2>
+<table>
+<tr><th>Source cluster</th><th>Destination cluster</th><th>Replications</th><th>RAM quota</th></tr>
+{{range .Links.Clusters}}<tr><td>{{.Source}}</td><td>{{.Destination}}</td><td>{{.Replications}}</td><td>{{.RamQuota}}</td></tr>
+{{end}}</table>
+<table>
+<tr><th>Source datacenter</th><th>Destination datacenter</th><th>Replications</th><th>RAM quota</th></tr>
+{{range .Links.Datacenters}}<tr><td>{{.Source}}</td><td>{{.Destination}}</td><td>{{.Replications}}</td><td>{{.RamQuota}}</td></tr>
+{{end}}</table>
+
+<h2>XDCR definitions</h2>
+{{range .Definitions}}
+<h3>{{.File}} #{{.Index}}: {{.Definition.Rule}}</h3>
+<ul>
+<li>source: {{selector .Definition.Source}}{{if .Definition.SourceExclude}} excluding {{selector .Definition.SourceExclude}}{{end}}</li>
+{{if .Definition.Destination}}<li>destination: {{selector .Definition.Destination}}{{if .Definition.DestinationExclude}} excluding {{selector .Definition.DestinationExclude}}{{end}}</li>{{end}}
+{{if .Definition.GroupOn}}<li>group on: {{join .Definition.GroupOn ", "}}</li>{{end}}
+<li>bidirectional: {{.Definition.Bidirectional}}</li>
+{{if .Definition.Color}}<li>color: <span style="color: {{.Definition.Color}}">{{.Definition.Color}}</span></li>{{end}}
+</ul>
+{{if .Replications}}<table>
+<tr><th>Source</th><th>Destination</th></tr>
+{{range .Replications}}<tr><td>{{.Source.Path}}</td><td>{{.Destination.Path}}</td></tr>
+{{end}}</table>{{else}}<p>No replication.</p>{{end}}
+{{end}}
+
+<h2>Graph</h2>
+{{if .SVG}}{{svg .SVG}}{{else}}<p class="warning">The graph is not rendered, graphviz is not installed. Its mermaid source follows.</p>
+<pre>{{.Mermaid}}</pre>{{end}}
+
+<h2>Analysis</h2>
+{{if not .Warnings}}<p>No warning.</p>{{end}}
+{{if .Problems}}<h3>Validation</h3>
+<ul>{{range .Problems}}<li class="{{level .Warning}}">{{.String}}</li>{{end}}</ul>{{end}}
+{{if .Conflicts}}<h3>Conflict resolution</h3>
+<ul>{{range .Conflicts}}<li class="warning">{{.String}}</li>{{end}}</ul>{{end}}
+{{if .Violations}}<h3>Policies</h3>
+<ul>{{range .Violations}}<li class="error">{{.String}}</li>{{end}}</ul>{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as a single html page, without external resource. The graph is inlined as SVG when
+// graphviz is available, else its mermaid source is shown as is.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, r)
+}
@@ -0,0 +1,100 @@
+package report
+
+import (
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+var funcs = map[string]interface{}{
+	"labels":   func(l model.Labels) string { return l.String() },
+	"selector": func(s model.Selector) string { return model.Labels(s).String() },
+	"join":     strings.Join,
+	// cell escapes the text of a markdown table cell
+	"cell": func(s string) string {
+		return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+	},
+	"level": func(warning bool) string {
+		if warning {
+			return "warning"
+		}
+		return "error"
+	},
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(funcs).Parse(`# {{.Title}}
+
+## Datacenters
+
+| Datacenter | Labels | Cluster groups | Clusters | Buckets | RAM quota |
+|---|---|---|---|---|---|
+{{range .Datacenters}}| {{cell .Name}} | {{labels .Labels | cell}} | {{.ClusterGroups}} | {{.Clusters}} | {{.Buckets}} | {{.RamQuota}} |
+{{end}}
+## Clusters
+
+| Cluster | Instance | Labels | Buckets | RAM quota |
+|---|---|---|---|---|
+{{range .Clusters}}| {{cell .Path}} | {{cell .Instance}} | {{labels .Labels | cell}} | {{.Buckets}} | {{.RamQuota}} |
+{{end}}
+## Buckets
+
+| Bucket | Name | RAM quota | Replicas | Inbound | Outbound | Labels |
+|---|---|---|---|---|---|---|
+{{range .Buckets}}| {{cell .Path}} | {{cell .Name}} | {{.RamQuota}} | {{.CBReplicateNumber}} | {{.Inbound}} | {{.Outbound}} | {{labels .Labels | cell}} |
+{{end}}
+## Replication links
+
+| Source cluster | Destination cluster | Replications | RAM quota |
+|---|---|---|---|
+{{range .Links.Clusters}}| {{cell .Source}} | {{cell .Destination}} | {{.Replications}} | {{.RamQuota}} |
+{{end}}
+| Source datacenter | Destination datacenter | Replications | RAM quota |
+|---|---|---|---|
+{{range .Links.Datacenters}}| {{cell .Source}} | {{cell .Destination}} | {{.Replications}} | {{.RamQuota}} |
+{{end}}
+## XDCR definitions
+{{range .Definitions}}
+### {{.File}} #{{.Index}}: {{.Definition.Rule}}
+
+- source: {{selector .Definition.Source}}{{if .Definition.SourceExclude}} excluding {{selector .Definition.SourceExclude}}{{end}}
+{{- if .Definition.Destination}}
+- destination: {{selector .Definition.Destination}}{{if .Definition.DestinationExclude}} excluding {{selector .Definition.DestinationExclude}}{{end}}{{end}}
+{{- if .Definition.GroupOn}}
+- group on: {{join .Definition.GroupOn ", "}}{{end}}
+- bidirectional: {{.Definition.Bidirectional}}
+{{- if .Definition.Color}}
+- color: {{.Definition.Color}}{{end}}
+
+{{if .Replications}}| Source | Destination |
+|---|---|
+{{range .Replications}}| {{cell .Source.Path}} | {{cell .Destination.Path}} |
+{{end}}{{else}}No replication.
+{{end}}{{end}}
+## Graph
+
+` + "```mermaid" + `
+{{.Mermaid}}` + "```" + `
+
+## Analysis
+{{if not .Warnings}}
+No warning.
+{{end}}{{if .Problems}}
+### Validation
+
+{{range .Problems}}- {{.String}}
+{{end}}{{end}}{{if .Conflicts}}
+### Conflict resolution
+
+{{range .Conflicts}}- {{.String}}
+{{end}}{{end}}{{if .Violations}}
+### Policies
+
+{{range .Violations}}- {{.String}}
+{{end}}{{end}}`))
+
+// WriteMarkdown writes the report as markdown, the graph being a mermaid diagram.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	return markdownTemplate.Execute(w, r)
+}
@@ -0,0 +1,25 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/expansion"
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func TestMarkdownCells(t *testing.T) {
+	r := &Report{
+		Title:       "report",
+		Datacenters: []DatacenterRow{{Name: "DC|1", Labels: model.Labels{"Zone": "a|b"}}},
+		Links:       &expansion.Physical{},
+	}
+	var out bytes.Buffer
+	if err := r.WriteMarkdown(&out); err != nil {
+		t.Fatal(err)
+	}
+	if want := `| DC\|1 | Zone=a\|b | 0 | 0 | 0 | 0 |`; !strings.Contains(out.String(), want) {
+		t.Errorf("WriteMarkdown() does not write %q:\n%s", want, out.String())
+	}
+}
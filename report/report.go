@@ -0,0 +1,144 @@
+// Package report builds a self-contained document describing an expanded blueprint: inventory, XDCR definitions
+// with the replications they produce, graph and analysis warnings.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dbenque/couchbaseblueprint/expansion"
+	"github.com/dbenque/couchbaseblueprint/model"
+	"github.com/dbenque/couchbaseblueprint/render"
+)
+
+// Report is the content of the document, written as markdown or html.
+type Report struct {
+	Title       string
+	Datacenters []DatacenterRow
+	Clusters    []ClusterRow
+	Buckets     []expansion.BucketQueryResult
+	Definitions []DefinitionSection
+	// Links are the replications aggregated per pair of clusters and of datacenters
+	Links      *expansion.Physical
+	Problems   []expansion.Problem
+	Conflicts  []expansion.ConflictIssue
+	Violations []expansion.Violation
+	// Mermaid is the graph in mermaid syntax, used by the markdown document and shown by the html one when SVG is empty
+	Mermaid string
+	// SVG is the graph laid out by graphviz, empty when the dot command is not available
+	SVG string
+}
+
+type DatacenterRow struct {
+	Name          string
+	Labels        model.Labels
+	ClusterGroups int
+	Clusters      int
+	Buckets       int
+	RamQuota      int
+}
+
+type ClusterRow struct {
+	Path     string
+	Instance string
+	Labels   model.Labels
+	Buckets  int
+	RamQuota int
+}
+
+// DefinitionSection is an XDCR definition with the replications it produces.
+type DefinitionSection struct {
+	File         string
+	Index        int
+	Definition   model.XDCRDef
+	Replications []model.XDCR
+}
+
+// New builds the report of the blueprint, the policies being optional.
+func New(title string, bp *expansion.Blueprint, policies []expansion.Policy) (*Report, error) {
+	// the definitions are evaluated once, for all the sections and checks
+	bp = bp.Expand()
+	r := &Report{
+		Title:       title,
+		Buckets:     expansion.QueryBuckets(bp, nil),
+		Problems:    expansion.Validate(bp),
+		Conflicts:   expansion.CheckConflicts(bp, 0),
+		Violations:  expansion.CheckPolicies(bp, policies),
+		Datacenters: []DatacenterRow{},
+		Clusters:    []ClusterRow{},
+		Definitions: []DefinitionSection{},
+	}
+
+	for _, dc := range bp.Datacenters {
+		row := DatacenterRow{Name: dc.Name, Labels: dc.Labels, ClusterGroups: len(dc.ClusterGroups)}
+		for _, cg := range dc.ClusterGroups {
+			for _, c := range cg.Clusters {
+				cr := ClusterRow{Path: c.Path(), Instance: c.Instance, Labels: c.Labels, Buckets: len(c.Buckets)}
+				for _, b := range c.Buckets {
+					cr.RamQuota += b.RamQuota
+				}
+				r.Clusters = append(r.Clusters, cr)
+				row.Clusters++
+				row.Buckets += cr.Buckets
+				row.RamQuota += cr.RamQuota
+			}
+		}
+		r.Datacenters = append(r.Datacenters, row)
+	}
+	sort.Slice(r.Clusters, func(i, j int) bool { return r.Clusters[i].Path < r.Clusters[j].Path })
+
+	for _, set := range bp.XDCRSets {
+		replications := set.Replications()
+		for i, def := range set.Definitions {
+			r.Definitions = append(r.Definitions, DefinitionSection{File: set.File, Index: i, Definition: def, Replications: replications[i]})
+		}
+	}
+
+	xdcrs := []model.XDCR{}
+	for _, d := range r.Definitions {
+		xdcrs = append(xdcrs, d.Replications...)
+	}
+	var mermaid bytes.Buffer
+	if err := (render.MermaidRenderer{}).Render(&mermaid, bp.Datacenters, xdcrs); err != nil {
+		return nil, err
+	}
+	r.Mermaid = mermaid.String()
+	r.Links = expansion.NewPhysical(xdcrs)
+
+	if render.GraphvizAvailable() {
+		var dot bytes.Buffer
+		if err := (render.DotRenderer{}).Render(&dot, bp.Datacenters, xdcrs); err != nil {
+			return nil, err
+		}
+		svg, err := render.DotSVG(dot.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		r.SVG = svg
+	}
+	return r, nil
+}
+
+// Formats maps the report formats to the content type they are served with.
+var Formats = map[string]string{
+	"markdown": "text/markdown; charset=utf-8",
+	"html":     "text/html; charset=utf-8",
+}
+
+// Write writes the report in one of the Formats.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "markdown":
+		return r.WriteMarkdown(w)
+	case "html":
+		return r.WriteHTML(w)
+	}
+	return fmt.Errorf("Unknown report format %q, expected markdown or html", format)
+}
+
+// Warnings is the number of analysis findings of the report.
+func (r *Report) Warnings() int {
+	return len(r.Problems) + len(r.Conflicts) + len(r.Violations)
+}
@@ -0,0 +1,73 @@
+package rules
+
+import (
+	"strconv"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func init() {
+	Register(model.CustomRule, RuleFunc(buildCustom))
+}
+
+// CustomPairs returns the indexes of the source and destination of each replication of the custom rule, following
+// the pairing of the definition:
+//   - cartesian (default): every source with every destination
+//   - zip: the i-th source with the i-th destination, the extra buckets of the longer side being left alone
+//   - match: every source with the destinations having the same values for the pairOn labels, the buckets missing one
+//     of them are left alone
+func CustomPairs(sources, destinations model.BucketByPath, def model.XDCRDef) [][2]int {
+	pairs := [][2]int{}
+	switch def.Pairing {
+	case model.ZipPairing:
+		for i := 0; i < len(sources) && i < len(destinations); i++ {
+			pairs = append(pairs, [2]int{i, i})
+		}
+	case model.MatchPairing:
+		// the destinations are looked up by their pairOn values rather than compared with every source
+		byKey := map[string][]int{}
+		for j, d := range destinations {
+			if key, ok := pairOnKey(d, def.PairOn); ok {
+				byKey[key] = append(byKey[key], j)
+			}
+		}
+		for i, s := range sources {
+			if key, ok := pairOnKey(s, def.PairOn); ok {
+				for _, j := range byKey[key] {
+					pairs = append(pairs, [2]int{i, j})
+				}
+			}
+		}
+	default:
+		for i := range sources {
+			for j := range destinations {
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+	}
+	return pairs
+}
+
+// pairOnKey returns the values of the pairOn labels of the bucket, false when one of them is missing.
+func pairOnKey(b model.Bucket, labels []string) (string, bool) {
+	key, found := "", true
+	for _, l := range labels {
+		v, ok := b.Labels[l]
+		found = found && ok
+		key += strconv.Quote(v)
+	}
+	return key, found
+}
+
+func buildCustom(sources, destinations model.BucketByPath, def model.XDCRDef) []model.XDCR {
+	result := []model.XDCR{}
+
+	for _, p := range CustomPairs(sources, destinations, def) {
+		s, d := sources[p[0]], destinations[p[1]]
+		result = append(result, model.XDCR{Source: s, Destination: d, Color: def.Color})
+		if def.Bidirectional {
+			result = append(result, model.XDCR{Source: d, Destination: s, Color: def.Color})
+		}
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func TestCustomPairs(t *testing.T) {
+	sources := model.BucketByPath{
+		testBucket("DC1", "A", "Zone", "a"),
+		testBucket("DC1", "B", "Zone", "b"),
+		testBucket("DC1", "C", "Zone", ""),
+		testBucket("DC1", "D"),
+	}
+	destinations := model.BucketByPath{
+		testBucket("DC2", "X", "Zone", "b"),
+		testBucket("DC2", "Y"),
+		testBucket("DC2", "Z", "Zone", "a"),
+	}
+	tests := []struct {
+		name string
+		def  model.XDCRDef
+		want []string
+	}{
+		{"cartesian", model.XDCRDef{Pairing: model.CartesianPairing}, []string{"A->X", "A->Y", "A->Z", "B->X", "B->Y", "B->Z", "C->X", "C->Y", "C->Z", "D->X", "D->Y", "D->Z"}},
+		{"zip", model.XDCRDef{Pairing: model.ZipPairing}, []string{"A->X", "B->Y", "C->Z"}},
+		// Y misses the label, it pairs with none of the sources, not even C having an empty value
+		{"match", model.XDCRDef{Pairing: model.MatchPairing, PairOn: []string{"Zone"}}, []string{"A->Z", "B->X"}},
+		{"match without pairOn", model.XDCRDef{Pairing: model.MatchPairing}, []string{"A->X", "A->Y", "A->Z", "B->X", "B->Y", "B->Z", "C->X", "C->Y", "C->Z", "D->X", "D->Y", "D->Z"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, p := range CustomPairs(sources, destinations, tt.def) {
+				got = append(got, sources[p[0]].Name+"->"+destinations[p[1]].Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CustomPairs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// the extra buckets of the longer side are left alone
+	if got, want := len(CustomPairs(destinations, sources, model.XDCRDef{Pairing: model.ZipPairing})), 3; got != want {
+		t.Errorf("CustomPairs(zip) has %d pairs, want %d", got, want)
+	}
+}
+
+func TestBuildCustom(t *testing.T) {
+	sources := model.BucketByPath{testBucket("DC1", "A"), testBucket("DC1", "B")}
+	destinations := model.BucketByPath{testBucket("DC2", "X"), testBucket("DC2", "Y")}
+	want := []string{"A->X", "X->A", "B->Y", "Y->B"}
+	if got := edges(buildCustom(sources, destinations, model.XDCRDef{Pairing: model.ZipPairing, Bidirectional: true})); !reflect.DeepEqual(got, want) {
+		t.Errorf("buildCustom() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,317 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// DefinitionExplanation is the evaluation of one XDCR definition for a pair of buckets.
+type DefinitionExplanation struct {
+	File             string         `json:"file"`
+	Index            int            `json:"index"`
+	Definition       model.XDCRDef  `json:"definition"`
+	Produced         bool           `json:"produced"`
+	SourceGroup      string         `json:"sourceGroup"`
+	DestinationGroup string         `json:"destinationGroup"`
+	Terms            []SelectorTerm `json:"terms"`
+	Steps            []string       `json:"steps"`
+}
+
+// SelectorTerm is the evaluation of one key of a selector against the labels of a bucket.
+type SelectorTerm struct {
+	Bucket   string `json:"bucket"`
+	Selector string `json:"selector"`
+	Key      string `json:"key"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Present  bool   `json:"present"`
+	Matched  bool   `json:"matched"`
+}
+
+// ExplainDefinition reports why the definition does or does not replicate the source bucket to the destination bucket.
+func ExplainDefinition(def model.XDCRDef, dcs []model.Datacenter, source, destination string) DefinitionExplanation {
+	de := DefinitionExplanation{Definition: def, Terms: []SelectorTerm{}, Steps: []string{}}
+
+	// the buckets are indexed once for the replications and the groups of the definition
+	ix := NewIndex(dcs)
+	for _, x := range ix.XDCRs(def) {
+		if x.Source.Path() == source && x.Destination.Path() == destination {
+			de.Produced = true
+			break
+		}
+	}
+
+	s, okS := model.FindBucket(dcs, source)
+	d, okD := model.FindBucket(dcs, destination)
+	if !okS || !okD {
+		de.Steps = append(de.Steps, "the buckets are not both part of the datacenters this definition applies to")
+		return de
+	}
+
+	de.SourceGroup = s.GroupHash(def.GroupOn)
+	de.DestinationGroup = d.GroupHash(def.GroupOn)
+
+	// ring and tree rules only use the source selectors, the destinations are taken among the sources
+	destinationSelector, destinationExclude := def.Destination, def.DestinationExclude
+	if !UsesDestination(def) {
+		destinationSelector, destinationExclude = def.Source, def.SourceExclude
+	}
+
+	de.Terms = append(de.Terms, explainSelector("source", "source", def.Source, s)...)
+	de.Terms = append(de.Terms, explainSelector("source", "sourceExclude", def.SourceExclude, s)...)
+	de.Terms = append(de.Terms, explainSelector("destination", "destination", destinationSelector, d)...)
+	de.Terms = append(de.Terms, explainSelector("destination", "destinationExclude", destinationExclude, d)...)
+
+	sourceSelected := de.explainSelection(source, "source", def.Source, def.SourceExclude, s)
+	destinationSelected := de.explainSelection(destination, "destination", destinationSelector, destinationExclude, d)
+	if !sourceSelected || !destinationSelected {
+		return de
+	}
+
+	if de.SourceGroup != de.DestinationGroup {
+		de.step("source group %s and destination group %s differ on groupOn %v", de.SourceGroup, de.DestinationGroup, def.GroupOn)
+		return de
+	}
+	de.step("both buckets fall into group %s", de.SourceGroup)
+
+	var group Group
+	for _, g := range ix.Groups(def) {
+		if g.Hash == de.SourceGroup {
+			group = g
+		}
+	}
+	switch def.Rule {
+	case model.CustomRule:
+		de.explainCustom(group, source, destination)
+	case model.RingRule:
+		de.explainRing(group.Sources, source, destination)
+	case model.TreeRule, model.UptreeRule:
+		de.explainTree(group.Sources, source, destination)
+	case model.StarRule:
+		de.explainStar(group.Sources, source, destination)
+	case model.MeshRule:
+		de.explainMesh(group.Sources, source, destination)
+	default:
+		if !Implemented(def.Rule) {
+			de.step("unknown rule %q, no replication is produced", def.Rule)
+		} else {
+			de.step("rule %q is a registered rule, it received the %d sources and %d destinations of the group", def.Rule, len(group.Sources), len(group.Destinations))
+		}
+	}
+
+	if de.Produced {
+		de.step("the replication %s -> %s is produced", source, destination)
+	} else {
+		de.step("the replication %s -> %s is not produced", source, destination)
+	}
+	return de
+}
+
+func (de *DefinitionExplanation) step(format string, a ...interface{}) {
+	de.Steps = append(de.Steps, fmt.Sprintf(format, a...))
+}
+
+func (de *DefinitionExplanation) explainSelection(path, role string, selector, exclude model.Selector, b model.Bucket) bool {
+	if selector == nil {
+		de.step("%s selector is missing, it matches no bucket", role)
+		return false
+	}
+	if !b.Match(selector) {
+		de.step("%s %s does not match the %s selector %s", role, path, role, selectorString(selector))
+		return false
+	}
+	if b.Match(exclude) {
+		de.step("%s %s is excluded by %s", role, path, selectorString(exclude))
+		return false
+	}
+	if len(selector) == 0 {
+		de.step("%s %s is selected by the empty %s selector, it matches every bucket", role, path, role)
+		return true
+	}
+	de.step("%s %s is selected by %s", role, path, selectorString(selector))
+	return true
+}
+
+func (de *DefinitionExplanation) explainCustom(group Group, source, destination string) {
+	def := de.Definition
+	switch def.Pairing {
+	case model.ZipPairing:
+		i, j := bucketIndex(group.Sources, source), bucketIndex(group.Destinations, destination)
+		de.step("zip pairing of %d sources with %d destinations by sort order", len(group.Sources), len(group.Destinations))
+		if i >= 0 && j >= 0 {
+			de.step("source is at position %d, destination at position %d", i, j)
+		}
+	case model.MatchPairing:
+		de.step("match pairing, a source replicates to the destinations having the same values for %v", def.PairOn)
+		i, j := bucketIndex(group.Sources, source), bucketIndex(group.Destinations, destination)
+		if i >= 0 && j >= 0 {
+			for _, l := range def.PairOn {
+				de.step("label %s: source=%q destination=%q", l, group.Sources[i].Labels[l], group.Destinations[j].Labels[l])
+			}
+		}
+	default:
+		de.step("custom rule replicates every source of the group to every destination of the group")
+	}
+	if def.Bidirectional {
+		de.step("bidirectional, the reverse replications are added")
+	}
+}
+
+func (de *DefinitionExplanation) explainRing(buckets model.BucketByPath, source, destination string) {
+	n := len(buckets)
+	if n < 2 {
+		de.step("the ring has %d bucket, at least 2 are needed", n)
+		return
+	}
+	i, j := bucketIndex(buckets, source), bucketIndex(buckets, destination)
+	de.step("ring of %d buckets, source at position %d, destination at position %d", n, i, j)
+	next := (i + 1) % n
+	de.step("the successor of position %d is %s", i, buckets[next].Path())
+	if de.Definition.Bidirectional {
+		if n > 2 {
+			de.step("bidirectional, the predecessor of position %d is %s", i, buckets[(i+n-1)%n].Path())
+		} else {
+			de.step("bidirectional is ignored for a ring of 2 buckets")
+		}
+	}
+}
+
+func (de *DefinitionExplanation) explainTree(buckets model.BucketByPath, source, destination string) {
+	levels := TreeLevels(buckets, de.Definition)
+	up := de.Definition.Rule == model.UptreeRule
+	de.step("levels on %v sorted %v", levelOn(de.Definition), levels)
+
+	levelIndex := func(path string) int {
+		for i, l := range levels {
+			if bucketIndex(l.Buckets, path) >= 0 {
+				return i
+			}
+		}
+		return -1
+	}
+	ls, ld := levelIndex(source), levelIndex(destination)
+	de.step("source is on level %q (#%d), destination is on level %q (#%d)", levels[ls], ls, levels[ld], ld)
+
+	if ls-ld != 1 && ld-ls != 1 {
+		de.step("the levels are not adjacent, the tree rules only link consecutive levels")
+		return
+	}
+
+	// parent is on the upper level, child on the next one
+	child := destination
+	if ld < ls {
+		child = source
+	}
+	fanOut := de.Definition.FanOut
+	if fanOut == "" {
+		fanOut = model.RoundRobinFanOut
+	}
+	for _, l := range TreeLinks(levels, de.Definition) {
+		if l.Child.Path() == child {
+			de.step("fan-out %s, %s: the parent of %s is %s", fanOut, l.Reason, child, l.Parent.Path())
+		}
+	}
+
+	if up {
+		de.step("uptree replicates from child to parent")
+	} else {
+		de.step("tree replicates from parent to child")
+	}
+	if de.Definition.Bidirectional {
+		de.step("bidirectional, the reverse replications are added")
+	}
+}
+
+func (de *DefinitionExplanation) explainStar(sources model.BucketByPath, source, destination string) {
+	hubs := StarHubs(sources, de.Definition)
+	paths := []string{}
+	for _, h := range hubs {
+		paths = append(paths, h.Path())
+	}
+	if len(de.Definition.Hub) > 0 {
+		de.step("hubs selected by %s: %v", selectorString(de.Definition.Hub), paths)
+	} else {
+		de.step("hubs are the first %d sources of the group: %v", len(hubs), paths)
+	}
+	sourceHub, destinationHub := bucketIndex(hubs, source) >= 0, bucketIndex(hubs, destination) >= 0
+	switch {
+	case sourceHub && destinationHub:
+		de.step("both buckets are hubs, hubs do not replicate to each other")
+	case sourceHub:
+		de.step("source is a hub, it replicates to every spoke")
+	case destinationHub && de.Definition.Bidirectional:
+		de.step("destination is a hub, bidirectional spokes replicate to the hubs")
+	case destinationHub:
+		de.step("destination is a hub, spokes replicate to the hubs only when bidirectional")
+	default:
+		de.step("both buckets are spokes, spokes do not replicate to each other")
+	}
+}
+
+func (de *DefinitionExplanation) explainMesh(sources model.BucketByPath, source, destination string) {
+	n := len(sources)
+	i, j := bucketIndex(sources, source), bucketIndex(sources, destination)
+	if i == j {
+		de.step("a bucket is never its own peer")
+		return
+	}
+	maxPeers := de.Definition.MaxPeers
+	if maxPeers <= 0 || maxPeers >= n-1 {
+		de.step("full mesh of %d buckets, every bucket is the peer of all the others", n)
+		return
+	}
+	de.step("mesh of %d buckets limited to %d peers, source at position %d, destination at position %d", n, maxPeers, i, j)
+	// the peers are the buckets at distance 1..maxPeers/2 around the sorted group, plus the opposite one when maxPeers
+	// is odd and n is even, as built by MeshPairs
+	distance := (j - i + n) % n
+	if distance > n-distance {
+		distance = n - distance
+	}
+	opposite := maxPeers%2 == 1 && n%2 == 0
+	switch {
+	case distance <= maxPeers/2:
+		de.step("the buckets are peers: they are at distance %d, within 1..%d around the sorted group", distance, maxPeers/2)
+	case opposite && distance == n/2:
+		de.step("the buckets are peers: they are opposite in the sorted group, maxPeers %d being odd", maxPeers)
+	case opposite:
+		de.step("the buckets are not peers: they are at distance %d, peers are at distance 1..%d around the sorted group or opposite (distance %d)", distance, maxPeers/2, n/2)
+	case maxPeers%2 == 1:
+		de.step("the buckets are not peers: they are at distance %d, peers are at distance 1..%d around the sorted group, the group having an odd number of buckets no bucket is opposite", distance, maxPeers/2)
+	default:
+		de.step("the buckets are not peers: they are at distance %d, peers are at distance 1..%d around the sorted group", distance, maxPeers/2)
+	}
+}
+
+// UsesDestination reports whether the rule of the definition takes its destinations from the destination selector.
+func UsesDestination(def model.XDCRDef) bool {
+	return def.Rule == model.CustomRule || (def.Rule == model.StarRule && def.Destination != nil)
+}
+
+func bucketIndex(buckets model.BucketByPath, path string) int {
+	for i, b := range buckets {
+		if b.Path() == path {
+			return i
+		}
+	}
+	return -1
+}
+
+func explainSelector(bucket, name string, s model.Selector, b model.Bucket) []SelectorTerm {
+	terms := []SelectorTerm{}
+	keys := []string{}
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := b.Labels[k]
+		terms = append(terms, SelectorTerm{Bucket: bucket, Selector: name, Key: k, Expected: s[k], Actual: v, Present: ok, Matched: ok && v == s[k]})
+	}
+	return terms
+}
+
+func selectorString(s model.Selector) string {
+	return "{" + model.Labels(s).String() + "}"
+}
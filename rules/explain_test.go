@@ -0,0 +1,92 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func TestExplainSelection(t *testing.T) {
+	b := testBucket("DC1", "A", "Role", "resa")
+	tests := []struct {
+		name              string
+		selector, exclude model.Selector
+		selected          bool
+		step              string
+	}{
+		{"missing", nil, nil, false, "source selector is missing, it matches no bucket"},
+		{"empty", model.Selector{}, nil, true, "source A is selected by the empty source selector, it matches every bucket"},
+		{"empty excluded", model.Selector{}, model.Selector{"Role": "resa"}, false, "source A is excluded by {Role=resa}"},
+		{"empty exclude", model.Selector{"Role": "resa"}, model.Selector{}, false, "source A is excluded by {}"},
+		{"not matching", model.Selector{"Role": "stat"}, nil, false, "source A does not match the source selector {Role=stat}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			de := &DefinitionExplanation{}
+			if got := de.explainSelection("A", "source", tt.selector, tt.exclude, b); got != tt.selected {
+				t.Errorf("explainSelection() = %v, want %v", got, tt.selected)
+			}
+			if want := []string{tt.step}; !reflect.DeepEqual(de.Steps, want) {
+				t.Errorf("explainSelection() steps = %v, want %v", de.Steps, want)
+			}
+		})
+	}
+}
+
+// TestExplainMesh checks that the explanation of a mesh agrees with MeshPairs on every pair of buckets.
+func TestExplainMesh(t *testing.T) {
+	for n := 3; n <= 8; n++ {
+		sources := model.BucketByPath{}
+		for i := 0; i < n; i++ {
+			sources = append(sources, testBucket("DC1", fmt.Sprintf("B%d", i)))
+		}
+		for maxPeers := 1; maxPeers < n-1; maxPeers++ {
+			peers := map[[2]int]bool{}
+			for _, p := range MeshPairs(n, maxPeers) {
+				peers[p], peers[[2]int{p[1], p[0]}] = true, true
+			}
+			for i := 0; i < n; i++ {
+				for j := 0; j < n; j++ {
+					if i == j {
+						continue
+					}
+					de := &DefinitionExplanation{Definition: model.XDCRDef{Rule: model.MeshRule, MaxPeers: maxPeers}}
+					de.explainMesh(sources, sources[i].Path(), sources[j].Path())
+					last := de.Steps[len(de.Steps)-1]
+					if got := strings.HasPrefix(last, "the buckets are peers"); got != peers[[2]int{i, j}] {
+						t.Errorf("explainMesh(%d buckets, maxPeers %d, %d, %d) = %q, want peers %v", n, maxPeers, i, j, last, peers[[2]int{i, j}])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestExplainMeshSteps(t *testing.T) {
+	tests := []struct {
+		n, maxPeers, i, j int
+		want              string
+	}{
+		{6, 2, 0, 1, "the buckets are peers: they are at distance 1, within 1..1 around the sorted group"},
+		{6, 3, 0, 3, "the buckets are peers: they are opposite in the sorted group, maxPeers 3 being odd"},
+		{6, 3, 0, 2, "the buckets are not peers: they are at distance 2, peers are at distance 1..1 around the sorted group or opposite (distance 3)"},
+		{7, 3, 0, 3, "the buckets are not peers: they are at distance 3, peers are at distance 1..1 around the sorted group, the group having an odd number of buckets no bucket is opposite"},
+		{7, 4, 5, 1, "the buckets are not peers: they are at distance 3, peers are at distance 1..2 around the sorted group"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d buckets %d peers %d-%d", tt.n, tt.maxPeers, tt.i, tt.j), func(t *testing.T) {
+			sources := model.BucketByPath{}
+			for i := 0; i < tt.n; i++ {
+				sources = append(sources, testBucket("DC1", fmt.Sprintf("B%d", i)))
+			}
+			de := &DefinitionExplanation{Definition: model.XDCRDef{Rule: model.MeshRule, MaxPeers: tt.maxPeers}}
+			de.explainMesh(sources, sources[tt.i].Path(), sources[tt.j].Path())
+			if last := de.Steps[len(de.Steps)-1]; last != tt.want {
+				t.Errorf("explainMesh() = %q, want %q", last, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package rules
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// Index gives the buckets of a set of datacenters by label value, so that the selectors of the definitions evaluated
+// against the same datacenters do not scan every bucket. An index is read only once built and can be shared by
+// goroutines.
+type Index struct {
+	buckets model.BucketByPath
+	// byLabel maps each label and value to the positions of the buckets having it, in increasing order
+	byLabel map[string]map[string][]int
+}
+
+// NewIndex indexes the buckets of the datacenters, sorted by path.
+func NewIndex(dcs []model.Datacenter) *Index {
+	ix := &Index{buckets: model.BucketByPath{}, byLabel: map[string]map[string][]int{}}
+	for _, dc := range dcs {
+		ix.buckets = append(ix.buckets, dc.GetBuckets()...)
+	}
+	sort.Stable(ix.buckets)
+	for i, b := range ix.buckets {
+		for k, v := range b.Labels {
+			values, ok := ix.byLabel[k]
+			if !ok {
+				values = map[string][]int{}
+				ix.byLabel[k] = values
+			}
+			values[v] = append(values[v], i)
+		}
+	}
+	return ix
+}
+
+// Buckets returns the indexed buckets sorted by path.
+func (ix *Index) Buckets() model.BucketByPath {
+	return ix.buckets
+}
+
+// Select returns the positions of the buckets matching the selector, in increasing order. As for Bucket.Match, a nil
+// selector matches no bucket and an empty one matches them all.
+func (ix *Index) Select(s model.Selector) []int {
+	if s == nil {
+		return nil
+	}
+	if len(s) == 0 {
+		all := make([]int, len(ix.buckets))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+	lists := [][]int{}
+	for k, v := range s {
+		l := ix.byLabel[k][v]
+		if len(l) == 0 {
+			return nil
+		}
+		lists = append(lists, l)
+	}
+	// intersect from the shortest list
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	result := lists[0]
+	for _, l := range lists[1:] {
+		result = intersect(result, l)
+	}
+	return result
+}
+
+// selectExcept returns the positions of the buckets matching s but not exclude.
+func (ix *Index) selectExcept(s, exclude model.Selector) []int {
+	selected := ix.Select(s)
+	if exclude == nil || len(selected) == 0 {
+		return selected
+	}
+	return subtract(selected, ix.Select(exclude))
+}
+
+// Groups returns the groups of buckets of the definition, as the Groups function does.
+func (ix *Index) Groups(def model.XDCRDef) []Group {
+	byHash := map[string]*Group{}
+	hashes := []string{}
+	group := func(h string) *Group {
+		g, ok := byHash[h]
+		if !ok {
+			g = &Group{Hash: h, Sources: model.BucketByPath{}, Destinations: model.BucketByPath{}}
+			byHash[h] = g
+			hashes = append(hashes, h)
+		}
+		return g
+	}
+
+	// the positions are increasing, the buckets of each group are appended sorted by path
+	for _, i := range ix.selectExcept(def.Source, def.SourceExclude) {
+		g := group(ix.buckets[i].GroupHash(def.GroupOn))
+		g.Sources = append(g.Sources, ix.buckets[i])
+	}
+	for _, i := range ix.selectExcept(def.Destination, def.DestinationExclude) {
+		g := group(ix.buckets[i].GroupHash(def.GroupOn))
+		g.Destinations = append(g.Destinations, ix.buckets[i])
+	}
+
+	sort.Strings(hashes)
+	result := []Group{}
+	for _, h := range hashes {
+		result = append(result, *byHash[h])
+	}
+	return result
+}
+
+// XDCRs returns the replications of the definition over the indexed buckets.
+func (ix *Index) XDCRs(def model.XDCRDef) []model.XDCR {
+	result := []model.XDCR{}
+	rule, ok := Lookup(def.Rule)
+	if !ok {
+		return result
+	}
+	for _, g := range ix.Groups(def) {
+		result = append(result, rule.Build(g.Sources, g.Destinations, def)...)
+	}
+	markReplications(result, def)
+	return result
+}
+
+// markReplications sets the priority of the definition on its replications, and flags the ones whose reverse it
+// produces as well.
+func markReplications(xdcrs []model.XDCR, def model.XDCRDef) {
+	pairs := map[[2]string]bool{}
+	for _, x := range xdcrs {
+		pairs[[2]string{x.Source.Path(), x.Destination.Path()}] = true
+	}
+	for i := range xdcrs {
+		xdcrs[i].Priority = def.Priority
+		xdcrs[i].Bidirectional = pairs[[2]string{xdcrs[i].Destination.Path(), xdcrs[i].Source.Path()}]
+	}
+}
+
+// NewXDCRs returns the replications of each definition over the same datacenters. The datacenters are indexed once
+// and the definitions are evaluated in parallel, the result is in the order of the definitions.
+func NewXDCRs(defs []model.XDCRDef, dcs []model.Datacenter) [][]model.XDCR {
+	result := make([][]model.XDCR, len(defs))
+	if len(defs) == 0 {
+		return result
+	}
+	ix := NewIndex(dcs)
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(defs) {
+		workers = len(defs)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				result[i] = ix.XDCRs(defs[i])
+			}
+		}()
+	}
+	for i := range defs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return result
+}
+
+func intersect(a, b []int) []int {
+	result := []int{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// subtract returns the positions of a that are not in b, both being increasing.
+func subtract(a, b []int) []int {
+	result := []int{}
+	j := 0
+	for _, p := range a {
+		for j < len(b) && b[j] < p {
+			j++
+		}
+		if j < len(b) && b[j] == p {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
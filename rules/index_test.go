@@ -0,0 +1,156 @@
+package rules
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// matchGroups is the grouping of the definitions before the index: every bucket is matched against the selectors.
+func matchGroups(def model.XDCRDef, dcs []model.Datacenter) []Group {
+	byHash := map[string]*Group{}
+	hashes := []string{}
+	group := func(h string) *Group {
+		g, ok := byHash[h]
+		if !ok {
+			g = &Group{Hash: h, Sources: model.BucketByPath{}, Destinations: model.BucketByPath{}}
+			byHash[h] = g
+			hashes = append(hashes, h)
+		}
+		return g
+	}
+	for _, dc := range dcs {
+		for _, b := range dc.GetBuckets() {
+			if b.Match(def.Source) && !b.Match(def.SourceExclude) {
+				g := group(b.GroupHash(def.GroupOn))
+				g.Sources = append(g.Sources, b)
+			}
+			if b.Match(def.Destination) && !b.Match(def.DestinationExclude) {
+				g := group(b.GroupHash(def.GroupOn))
+				g.Destinations = append(g.Destinations, b)
+			}
+		}
+	}
+	sort.Strings(hashes)
+	result := []Group{}
+	for _, h := range hashes {
+		g := byHash[h]
+		sort.Sort(g.Sources)
+		sort.Sort(g.Destinations)
+		result = append(result, *g)
+	}
+	return result
+}
+
+var indexSelectors = []struct {
+	name     string
+	selector model.Selector
+}{
+	{"nil", nil},
+	{"empty", model.Selector{}},
+	{"one label", model.Selector{"Role": "R1"}},
+	{"datacenter label", model.Selector{"Level": "2"}},
+	{"two labels", model.Selector{"Role": "R1", "Level": "2"}},
+	{"unknown value", model.Selector{"Role": "R9"}},
+	{"unknown label", model.Selector{"Rack": "1"}},
+	{"empty value", model.Selector{"Role": ""}},
+}
+
+func TestIndexSelect(t *testing.T) {
+	dcs := syntheticDatacenters(7, 2, 3)
+	ix := NewIndex(dcs)
+	for _, tt := range indexSelectors {
+		t.Run(tt.name, func(t *testing.T) {
+			want := []string{}
+			for _, dc := range dcs {
+				for _, b := range dc.GetBuckets() {
+					if b.Match(tt.selector) {
+						want = append(want, b.Path())
+					}
+				}
+			}
+			sort.Strings(want)
+			got := []string{}
+			for _, i := range ix.Select(tt.selector) {
+				got = append(got, ix.Buckets()[i].Path())
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Select(%v) = %v, want %v", tt.selector, got, want)
+			}
+		})
+	}
+}
+
+func TestIndexGroups(t *testing.T) {
+	dcs := syntheticDatacenters(7, 2, 3)
+	ix := NewIndex(dcs)
+	defs := []struct {
+		name string
+		def  model.XDCRDef
+	}{
+		{"source only", model.XDCRDef{Source: model.Selector{"Role": "R1"}}},
+		{"grouped", model.XDCRDef{Source: model.Selector{"Role": "R1"}, GroupOn: []string{"Region"}}},
+		{"grouped on two labels", model.XDCRDef{Source: model.Selector{}, GroupOn: []string{"Region", "Level"}}},
+		{"destination", model.XDCRDef{Source: model.Selector{"Level": "1"}, Destination: model.Selector{"Level": "2"}, GroupOn: []string{"Role"}}},
+		{"excludes", model.XDCRDef{Source: model.Selector{"Role": "R0"}, SourceExclude: model.Selector{"Level": "1"}, Destination: model.Selector{}, DestinationExclude: model.Selector{"Role": "R0"}, GroupOn: []string{"Datacenter"}}},
+		{"empty exclude", model.XDCRDef{Source: model.Selector{"Role": "R2"}, SourceExclude: model.Selector{}}},
+		{"no match", model.XDCRDef{Source: model.Selector{"Role": "R9"}, Destination: model.Selector{"Rack": "1"}}},
+		{"missing group label", model.XDCRDef{Source: model.Selector{"Role": "R1"}, GroupOn: []string{"Rack"}}},
+	}
+	for _, tt := range defs {
+		t.Run(tt.name, func(t *testing.T) {
+			want := matchGroups(tt.def, dcs)
+			if got := ix.Groups(tt.def); !reflect.DeepEqual(got, want) {
+				t.Errorf("Groups() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestNewXDCRsOrder(t *testing.T) {
+	dcs := syntheticDatacenters(10, 2, 4)
+	defs := syntheticXDCRDefs(4)
+	got := NewXDCRs(defs, dcs)
+	if len(got) != len(defs) {
+		t.Fatalf("NewXDCRs() returned %d lists, want %d", len(got), len(defs))
+	}
+	for i, def := range defs {
+		if want := NewXDCR(def, dcs); !reflect.DeepEqual(got[i], want) {
+			t.Errorf("NewXDCRs()[%d] = %v, want %v", i, got[i], want)
+		}
+	}
+}
+
+func BenchmarkIndex(b *testing.B) {
+	dcs := syntheticDatacenters(100, 10, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewIndex(dcs)
+	}
+}
+
+// BenchmarkNewXDCR evaluates one definition after the other, each one indexing the buckets again.
+func BenchmarkNewXDCR(b *testing.B) {
+	dcs := syntheticDatacenters(100, 10, 10)
+	defs := syntheticXDCRDefs(10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, def := range defs {
+			NewXDCR(def, dcs)
+		}
+	}
+}
+
+func BenchmarkNewXDCRs(b *testing.B) {
+	dcs := syntheticDatacenters(100, 10, 10)
+	defs := syntheticXDCRDefs(10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewXDCRs(defs, dcs)
+	}
+}
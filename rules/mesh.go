@@ -0,0 +1,54 @@
+package rules
+
+import "github.com/dbenque/couchbaseblueprint/model"
+
+func init() {
+	Register(model.MeshRule, RuleFunc(buildMesh))
+}
+
+// MeshPairs returns the pairs of peers, as positions i < j, of a mesh of n buckets. When maxPeers is set and lower
+// than n-1 the mesh is a maxPeers-regular circulant overlay: each bucket is the peer of the buckets at distance
+// 1..maxPeers/2 around the ring, plus the opposite bucket when maxPeers is odd and n is even.
+func MeshPairs(n, maxPeers int) [][2]int {
+	pairs := [][2]int{}
+	if maxPeers <= 0 || maxPeers >= n-1 {
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+		return pairs
+	}
+
+	seen := map[[2]int]bool{}
+	add := func(i, j int) {
+		if i > j {
+			i, j = j, i
+		}
+		p := [2]int{i, j}
+		if i != j && !seen[p] {
+			seen[p] = true
+			pairs = append(pairs, p)
+		}
+	}
+	for i := 0; i < n; i++ {
+		for d := 1; d <= maxPeers/2; d++ {
+			add(i, (i+d)%n)
+		}
+		if maxPeers%2 == 1 && n%2 == 0 {
+			add(i, (i+n/2)%n)
+		}
+	}
+	return pairs
+}
+
+// buildMesh replicates every source of the group to its peers, in both directions. A bucket is never its own peer.
+func buildMesh(sources, destinations model.BucketByPath, def model.XDCRDef) []model.XDCR {
+	result := []model.XDCR{}
+	for _, p := range MeshPairs(len(sources), def.MaxPeers) {
+		a, b := sources[p[0]], sources[p[1]]
+		result = append(result, model.XDCR{Source: a, Destination: b, Color: def.Color})
+		result = append(result, model.XDCR{Source: b, Destination: a, Color: def.Color})
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func TestMeshPairs(t *testing.T) {
+	tests := []struct {
+		n, maxPeers int
+		// degree is the number of peers of every bucket
+		degree int
+	}{
+		{1, 0, 0},
+		{4, 0, 3},
+		{5, 4, 4},
+		{5, 9, 4},
+		{6, 2, 2},
+		{6, 3, 3},
+		{6, 4, 4},
+		{7, 2, 2},
+		// the odd peer is the opposite bucket, there is none when n is odd
+		{7, 3, 2},
+		{8, 1, 1},
+		{10, 5, 5},
+	}
+	for _, tt := range tests {
+		pairs := MeshPairs(tt.n, tt.maxPeers)
+		degree := make([]int, tt.n)
+		seen := map[[2]int]bool{}
+		for _, p := range pairs {
+			if p[0] >= p[1] || p[0] < 0 || p[1] >= tt.n {
+				t.Errorf("MeshPairs(%d, %d) has the pair %v, expected i < j < n", tt.n, tt.maxPeers, p)
+			}
+			if seen[p] {
+				t.Errorf("MeshPairs(%d, %d) has the pair %v twice", tt.n, tt.maxPeers, p)
+			}
+			seen[p] = true
+			degree[p[0]]++
+			degree[p[1]]++
+		}
+		for i, d := range degree {
+			if d != tt.degree {
+				t.Errorf("MeshPairs(%d, %d): bucket %d has %d peers, want %d", tt.n, tt.maxPeers, i, d, tt.degree)
+			}
+		}
+	}
+}
+
+func TestBuildMesh(t *testing.T) {
+	sources := model.BucketByPath{testBucket("DC1", "A"), testBucket("DC1", "B"), testBucket("DC1", "C")}
+	want := []string{"A->B", "B->A", "A->C", "C->A", "B->C", "C->B"}
+	if got := edges(buildMesh(sources, nil, model.XDCRDef{})); !reflect.DeepEqual(got, want) {
+		t.Errorf("buildMesh() = %v, want %v", got, want)
+	}
+
+	sources = model.BucketByPath{}
+	for _, name := range []string{"A", "B", "C", "D", "E", "F"} {
+		sources = append(sources, testBucket("DC1", name))
+	}
+	for _, x := range buildMesh(sources, nil, model.XDCRDef{MaxPeers: 3}) {
+		if x.Source.Path() == x.Destination.Path() {
+			t.Errorf("buildMesh() replicates %s to itself", x.Source.Name)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[model.XDCRRule]Rule{}
+)
+
+// Register makes a rule available under the given name. It panics if the name is already registered or the rule is nil.
+func Register(name model.XDCRRule, rule Rule) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if rule == nil {
+		panic(fmt.Sprintf("rules: Register rule %q is nil", name))
+	}
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("rules: Register called twice for rule %q", name))
+	}
+	registry[name] = rule
+}
+
+// Lookup returns the rule registered under name.
+func Lookup(name model.XDCRRule) (Rule, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registry[name]
+	return r, ok
+}
+
+// Implemented reports whether a rule is registered under name.
+func Implemented(name model.XDCRRule) bool {
+	_, ok := Lookup(name)
+	return ok
+}
+
+// Names returns the sorted names of the registered rules.
+func Names() []model.XDCRRule {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := []model.XDCRRule{}
+	for n := range registry {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
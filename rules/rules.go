@@ -0,0 +1,72 @@
+// Package rules builds the XDCR replications of a set of datacenters from XDCR definitions.
+//
+// The rule of a definition is looked up in a registry holding the built-in rules (uptree, tree, ring, custom, star,
+// mesh). Other packages can add topologies by registering their own implementation:
+//
+//	func init() {
+//		rules.Register("hypercube", rules.RuleFunc(buildHypercube))
+//	}
+//
+// The registered rules are called concurrently, see Rule.
+package rules
+
+import "github.com/dbenque/couchbaseblueprint/model"
+
+// Rule builds the replications of one group of buckets. The sources and destinations are the buckets of the group
+// selected by the definition, sorted by path. Rules using only the source selector ignore the destinations.
+//
+// Build must be safe for concurrent use: NewXDCRs evaluates several definitions at once, calling the rules from as
+// many goroutines as GOMAXPROCS. The buckets, and their labels, are shared by these calls and must not be modified.
+type Rule interface {
+	Build(sources, destinations model.BucketByPath, def model.XDCRDef) []model.XDCR
+}
+
+// RuleFunc adapts a function to the Rule interface.
+type RuleFunc func(sources, destinations model.BucketByPath, def model.XDCRDef) []model.XDCR
+
+func (f RuleFunc) Build(sources, destinations model.BucketByPath, def model.XDCRDef) []model.XDCR {
+	return f(sources, destinations, def)
+}
+
+// Group is the set of buckets sharing the same values for the groupOn labels of a definition.
+type Group struct {
+	Hash         string
+	Sources      model.BucketByPath
+	Destinations model.BucketByPath
+}
+
+// NewXDCR returns the replications of the definition over the datacenters. NewXDCRs evaluates several definitions
+// over the same datacenters at once.
+func NewXDCR(def model.XDCRDef, dcs []model.Datacenter) []model.XDCR {
+	return NewIndex(dcs).XDCRs(def)
+}
+
+// Groups returns the groups of buckets of the definition ordered by hash, the buckets of each group sorted by path.
+func Groups(def model.XDCRDef, dcs []model.Datacenter) []Group {
+	return NewIndex(dcs).Groups(def)
+}
+
+func init() {
+	Register(model.RingRule, RuleFunc(func(sources, destinations model.BucketByPath, def model.XDCRDef) []model.XDCR {
+		return buildRing(sources, def)
+	}))
+}
+
+func buildRing(buckets model.BucketByPath, def model.XDCRDef) []model.XDCR {
+	result := []model.XDCR{}
+	if len(buckets) < 2 {
+		return result
+	}
+
+	for i := 0; i < len(buckets)-1; i++ {
+		result = append(result, model.XDCR{Source: buckets[i], Destination: buckets[i+1], Color: def.Color})
+		if def.Bidirectional && len(buckets) > 2 {
+			result = append(result, model.XDCR{Destination: buckets[i], Source: buckets[i+1], Color: def.Color})
+		}
+	}
+	result = append(result, model.XDCR{Source: buckets[len(buckets)-1], Destination: buckets[0], Color: def.Color})
+	if def.Bidirectional && len(buckets) > 2 {
+		result = append(result, model.XDCR{Destination: buckets[len(buckets)-1], Source: buckets[0], Color: def.Color})
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// testBucket returns a bucket of the datacenter with the labels given as key, value pairs.
+func testBucket(dc, name string, labels ...string) model.Bucket {
+	b := model.Bucket{Name: name, Labels: model.Labels{"Datacenter": dc}}
+	for i := 0; i+1 < len(labels); i += 2 {
+		b.Labels[labels[i]] = labels[i+1]
+	}
+	return b
+}
+
+// edges returns the replications written source->destination with the bucket names.
+func edges(xdcrs []model.XDCR) []string {
+	result := []string{}
+	for _, x := range xdcrs {
+		result = append(result, x.Source.Name+"->"+x.Destination.Name)
+	}
+	return result
+}
+
+func names(buckets model.BucketByPath) []string {
+	result := []string{}
+	for _, b := range buckets {
+		result = append(result, b.Name)
+	}
+	return result
+}
+
+func TestBuildRing(t *testing.T) {
+	buckets := model.BucketByPath{testBucket("DC1", "A"), testBucket("DC1", "B"), testBucket("DC1", "C")}
+	tests := []struct {
+		name    string
+		buckets model.BucketByPath
+		def     model.XDCRDef
+		want    []string
+	}{
+		{"single bucket", buckets[:1], model.XDCRDef{}, []string{}},
+		{"two buckets", buckets[:2], model.XDCRDef{}, []string{"A->B", "B->A"}},
+		{"ring", buckets, model.XDCRDef{}, []string{"A->B", "B->C", "C->A"}},
+		{"bidirectional", buckets, model.XDCRDef{Bidirectional: true}, []string{"A->B", "B->A", "B->C", "C->B", "C->A", "A->C"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := edges(buildRing(tt.buckets, tt.def)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildRing() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package rules
+
+import "github.com/dbenque/couchbaseblueprint/model"
+
+func init() {
+	Register(model.StarRule, RuleFunc(buildStar))
+}
+
+// StarHubs returns the hubs of a group: the sources matching def.Hub, or when it is not set the first def.HubCount
+// sources (at least one).
+func StarHubs(sources model.BucketByPath, def model.XDCRDef) model.BucketByPath {
+	hubs := model.BucketByPath{}
+	if def.Hub != nil {
+		for _, b := range sources {
+			if b.Match(def.Hub) {
+				hubs = append(hubs, b)
+			}
+		}
+		return hubs
+	}
+
+	count := def.HubCount
+	if count < 1 {
+		count = 1
+	}
+	if count > len(sources) {
+		count = len(sources)
+	}
+	return append(hubs, sources[:count]...)
+}
+
+// buildStar replicates each hub of the group to all the other buckets of the group, the spokes.
+// The spokes are the destinations when a destination selector is set, the other sources otherwise.
+func buildStar(sources, destinations model.BucketByPath, def model.XDCRDef) []model.XDCR {
+	result := []model.XDCR{}
+	hubs := StarHubs(sources, def)
+	isHub := map[string]bool{}
+	for _, h := range hubs {
+		isHub[h.Path()] = true
+	}
+
+	spokes := sources
+	if def.Destination != nil {
+		spokes = destinations
+	}
+
+	for _, h := range hubs {
+		for _, s := range spokes {
+			if isHub[s.Path()] {
+				continue
+			}
+			result = append(result, model.XDCR{Source: h, Destination: s, Color: def.Color})
+			if def.Bidirectional {
+				result = append(result, model.XDCR{Source: s, Destination: h, Color: def.Color})
+			}
+		}
+	}
+	return result
+}
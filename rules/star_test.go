@@ -0,0 +1,58 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func TestStarHubs(t *testing.T) {
+	sources := model.BucketByPath{
+		testBucket("DC1", "A"),
+		testBucket("DC1", "B", "Role", "hub"),
+		testBucket("DC1", "C"),
+		testBucket("DC1", "D", "Role", "hub"),
+	}
+	tests := []struct {
+		name string
+		def  model.XDCRDef
+		want []string
+	}{
+		{"default", model.XDCRDef{}, []string{"A"}},
+		{"hub selector", model.XDCRDef{Hub: model.Selector{"Role": "hub"}}, []string{"B", "D"}},
+		{"hub selector over hubCount", model.XDCRDef{Hub: model.Selector{"Role": "hub"}, HubCount: 3}, []string{"B", "D"}},
+		{"hub selector without match", model.XDCRDef{Hub: model.Selector{"Role": "none"}}, []string{}},
+		{"hubCount", model.XDCRDef{HubCount: 2}, []string{"A", "B"}},
+		{"hubCount over the sources", model.XDCRDef{HubCount: 9}, []string{"A", "B", "C", "D"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(StarHubs(sources, tt.def)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StarHubs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildStar(t *testing.T) {
+	sources := model.BucketByPath{testBucket("DC1", "A"), testBucket("DC1", "B", "Role", "hub"), testBucket("DC1", "C")}
+	destinations := model.BucketByPath{testBucket("DC2", "X"), testBucket("DC2", "Y")}
+	tests := []struct {
+		name string
+		def  model.XDCRDef
+		want []string
+	}{
+		{"hub selector", model.XDCRDef{Hub: model.Selector{"Role": "hub"}}, []string{"B->A", "B->C"}},
+		{"hubs are not spokes", model.XDCRDef{HubCount: 2}, []string{"A->C", "B->C"}},
+		{"bidirectional", model.XDCRDef{Bidirectional: true}, []string{"A->B", "B->A", "A->C", "C->A"}},
+		{"destinations are the spokes", model.XDCRDef{Destination: model.Selector{"Datacenter": "DC2"}}, []string{"A->X", "A->Y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := edges(buildStar(sources, destinations, tt.def)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildStar() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
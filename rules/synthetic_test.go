@@ -0,0 +1,54 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+// syntheticRegions is the number of regions the datacenters of a synthetic blueprint are spread over.
+const syntheticRegions = 5
+
+// syntheticDatacenters returns dcCount datacenters, each holding clusterCount clusters of bucketCount buckets. The
+// datacenters are labeled with a Region and a Level from 1 to 3, the buckets with a Role.
+func syntheticDatacenters(dcCount, clusterCount, bucketCount int) []model.Datacenter {
+	instances := []string{}
+	for i := 0; i < clusterCount; i++ {
+		instances = append(instances, strconv.Itoa(i))
+	}
+	buckets := []model.Bucket{}
+	for i := 0; i < bucketCount; i++ {
+		buckets = append(buckets, model.Bucket{Name: fmt.Sprintf("B%d", i), RamQuota: 256, Labels: model.Labels{"Role": fmt.Sprintf("R%d", i)}})
+	}
+	cgdef := model.ClusterGroupDef{
+		Name:        "CG",
+		PeakTokens:  []string{"PK"},
+		ClusterDefs: []model.ClusterDef{{Name: "C", Instances: instances, Buckets: buckets}},
+	}
+	dcs := []model.Datacenter{}
+	for i := 0; i < dcCount; i++ {
+		dc := model.NewDatacenterFromDef(model.DatacenterDef{
+			Name:   fmt.Sprintf("DC%d", i+1),
+			Labels: model.Labels{"Region": fmt.Sprintf("R%d", i%syntheticRegions), "Level": strconv.Itoa(1 + (i/syntheticRegions)%3)},
+		})
+		dc.AddClusterGroupDef(cgdef)
+		dcs = append(dcs, dc)
+	}
+	return dcs
+}
+
+// syntheticXDCRDefs replicates every role by a tree over the levels of each region, a zip between the first two
+// levels and a ring inside each datacenter.
+func syntheticXDCRDefs(bucketCount int) []model.XDCRDef {
+	defs := []model.XDCRDef{}
+	for i := 0; i < bucketCount; i++ {
+		role := fmt.Sprintf("R%d", i)
+		defs = append(defs,
+			model.XDCRDef{Rule: model.TreeRule, Source: model.Selector{"Role": role}, GroupOn: []string{"Region"}, FanOut: model.BalancedFanOut, Color: "blue"},
+			model.XDCRDef{Rule: model.CustomRule, Source: model.Selector{"Role": role, "Level": "1"}, Destination: model.Selector{"Role": role, "Level": "2"}, GroupOn: []string{"Region"}, Pairing: model.ZipPairing, Color: "red"},
+			model.XDCRDef{Rule: model.RingRule, Source: model.Selector{"Role": role}, GroupOn: []string{"Datacenter"}, Color: "green"},
+		)
+	}
+	return defs
+}
@@ -0,0 +1,235 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func init() {
+	Register(model.TreeRule, RuleFunc(func(sources, destinations model.BucketByPath, def model.XDCRDef) []model.XDCR {
+		return buildTree(sources, def, false)
+	}))
+	Register(model.UptreeRule, RuleFunc(func(sources, destinations model.BucketByPath, def model.XDCRDef) []model.XDCR {
+		return buildTree(sources, def, true)
+	}))
+}
+
+// DefaultLevelOn is the label holding the level of a bucket when the definition has no levelOn.
+var DefaultLevelOn = []string{"Level"}
+
+// DefaultAffinity is the label a child and its parent should share with the affinity fan-out when the definition has
+// no affinity labels.
+var DefaultAffinity = []string{"Datacenter"}
+
+// TreeLevel is the set of buckets sharing the same level key.
+type TreeLevel struct {
+	Key     []string
+	Buckets model.BucketByPath
+}
+
+func (l TreeLevel) String() string {
+	return strings.Join(l.Key, "/")
+}
+
+// TreeLink is the assignment of a child bucket to its parent on the previous level.
+type TreeLink struct {
+	Parent model.Bucket
+	Child  model.Bucket
+	// Level is the index of the level of the child
+	Level  int
+	Reason string
+}
+
+func buildTree(buckets model.BucketByPath, def model.XDCRDef, up bool) []model.XDCR {
+	result := []model.XDCR{}
+
+	for _, l := range TreeLinks(TreeLevels(buckets, def), def) {
+		s, d := l.Parent, l.Child
+		if up {
+			s, d = d, s
+		}
+
+		result = append(result, model.XDCR{Source: s, Destination: d, Color: def.Color})
+		if def.Bidirectional {
+			result = append(result, model.XDCR{Source: d, Destination: s, Color: def.Color})
+		}
+	}
+
+	return result
+}
+
+func levelOn(def model.XDCRDef) []string {
+	if len(def.LevelOn) > 0 {
+		return def.LevelOn
+	}
+	return DefaultLevelOn
+}
+
+// TreeLevels indexes the buckets by the values of the levelOn labels of the definition and returns the levels sorted
+// key by key, numeric values being compared as numbers. The buckets of each level are sorted by path, the numbers of
+// the paths being compared as numbers as well so that the instance 2 of a cluster comes before the instance 10.
+func TreeLevels(buckets model.BucketByPath, def model.XDCRDef) []TreeLevel {
+	labels := levelOn(def)
+	byKey := map[string]*TreeLevel{}
+	levels := []*TreeLevel{}
+
+	for _, b := range buckets {
+		key := []string{}
+		for _, l := range labels {
+			key = append(key, b.Labels[l])
+		}
+		k := strings.Join(key, "\x00")
+		level, ok := byKey[k]
+		if !ok {
+			level = &TreeLevel{Key: key, Buckets: model.BucketByPath{}}
+			byKey[k] = level
+			levels = append(levels, level)
+		}
+		level.Buckets = append(level.Buckets, b)
+	}
+
+	sort.SliceStable(levels, func(i, j int) bool { return compareLevelKeys(levels[i].Key, levels[j].Key) < 0 })
+
+	result := []TreeLevel{}
+	for _, l := range levels {
+		sort.SliceStable(l.Buckets, func(i, j int) bool { return comparePaths(l.Buckets[i].Path(), l.Buckets[j].Path()) < 0 })
+		result = append(result, *l)
+	}
+	return result
+}
+
+func compareLevelKeys(a, b []string) int {
+	for i := range a {
+		if c := compareLevelValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareLevelValues compares numerically when both values are numbers, so that level "2" comes before level "10".
+func compareLevelValues(a, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	switch {
+	case errA == nil && errB == nil:
+		if fa < fb {
+			return -1
+		}
+		if fa > fb {
+			return 1
+		}
+		return 0
+	case errA == nil:
+		// numbers before names
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// comparePaths compares the paths piece by piece, the runs of digits being compared as numbers.
+func comparePaths(a, b string) int {
+	for a != "" && b != "" {
+		pa, pb := pathPiece(a), pathPiece(b)
+		a, b = a[len(pa):], b[len(pb):]
+		if isDigit(pa[0]) && isDigit(pb[0]) {
+			na, nb := strings.TrimLeft(pa, "0"), strings.TrimLeft(pb, "0")
+			if len(na) != len(nb) {
+				if len(na) < len(nb) {
+					return -1
+				}
+				return 1
+			}
+			pa, pb = na, nb
+		}
+		if c := strings.Compare(pa, pb); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// pathPiece returns the leading run of digits, or of other characters, of a non empty string.
+func pathPiece(s string) string {
+	digit := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digit {
+		i++
+	}
+	return s[:i]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// TreeLinks assigns a parent of the previous level to every bucket of each level, following the fan-out strategy of
+// the definition.
+func TreeLinks(levels []TreeLevel, def model.XDCRDef) []TreeLink {
+	links := []TreeLink{}
+
+	affinity := def.Affinity
+	if len(affinity) == 0 && def.FanOut == model.AffinityFanOut {
+		affinity = DefaultAffinity
+	}
+
+	for i := 0; i < len(levels)-1; i++ {
+		parents := levels[i].Buckets
+		fanOut := map[string]int{}
+
+		for j, child := range levels[i+1].Buckets {
+			candidates := parents
+			reason := ""
+			if len(affinity) > 0 {
+				if same := sameLabels(parents, child, affinity); len(same) > 0 {
+					candidates = same
+					reason = fmt.Sprintf("%d parent(s) share %v with the child; ", len(same), affinity)
+				} else {
+					reason = fmt.Sprintf("no parent shares %v with the child; ", affinity)
+				}
+			}
+
+			var parent model.Bucket
+			switch def.FanOut {
+			case model.BalancedFanOut, model.AffinityFanOut:
+				parent = candidates[0]
+				for _, c := range candidates[1:] {
+					if fanOut[c.Path()] < fanOut[parent.Path()] {
+						parent = c
+					}
+				}
+				reason += fmt.Sprintf("balanced: the parent with the lowest fan-out (%d) is chosen", fanOut[parent.Path()])
+			default:
+				parent = candidates[j%len(candidates)]
+				reason += fmt.Sprintf("round-robin: child position %d %% %d parent(s) = %d", j, len(candidates), j%len(candidates))
+			}
+			fanOut[parent.Path()]++
+
+			links = append(links, TreeLink{Parent: parent, Child: child, Level: i + 1, Reason: reason})
+		}
+	}
+	return links
+}
+
+func sameLabels(buckets model.BucketByPath, b model.Bucket, labels []string) model.BucketByPath {
+	result := model.BucketByPath{}
+	for _, c := range buckets {
+		match := true
+		for _, l := range labels {
+			if c.Labels[l] != b.Labels[l] {
+				match = false
+				break
+			}
+		}
+		if match {
+			result = append(result, c)
+		}
+	}
+	return result
+}
@@ -0,0 +1,153 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dbenque/couchbaseblueprint/model"
+)
+
+func TestTreeLevels(t *testing.T) {
+	buckets := model.BucketByPath{
+		testBucket("DC1", "A", "Level", "10", "Tier", "b"),
+		testBucket("DC2", "B", "Level", "2", "Tier", "a"),
+		testBucket("DC3", "C", "Level", "core", "Tier", "a"),
+		testBucket("DC4", "D", "Level", "1", "Tier", "b"),
+		testBucket("DC5", "E", "Level", "2", "Tier", "b"),
+		testBucket("DC6", "F", "Level", "1.5", "Tier", "a"),
+	}
+	tests := []struct {
+		name string
+		def  model.XDCRDef
+		want []string
+	}{
+		{"numeric", model.XDCRDef{}, []string{"1", "1.5", "2", "10", "core"}},
+		{"levelOn", model.XDCRDef{LevelOn: []string{"Tier"}}, []string{"a", "b"}},
+		{"multi-key", model.XDCRDef{LevelOn: []string{"Tier", "Level"}}, []string{"a/1.5", "a/2", "a/core", "b/1", "b/2", "b/10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, l := range TreeLevels(buckets, tt.def) {
+				got = append(got, l.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TreeLevels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareLevelValues(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2", "10", -1},
+		{"10", "2", 1},
+		{"2", "2.0", 0},
+		{"-1", "0", -1},
+		{"9", "core", -1},
+		{"core", "9", 1},
+		{"core", "edge", -1},
+		{"", "1", 1},
+	}
+	for _, tt := range tests {
+		if got := compareLevelValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareLevelValues(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTreeLinks(t *testing.T) {
+	parents := model.BucketByPath{testBucket("DC1", "P1", "Level", "1"), testBucket("DC2", "P2", "Level", "1")}
+	children := model.BucketByPath{
+		testBucket("DC1", "C1", "Level", "2"),
+		testBucket("DC1", "C2", "Level", "2"),
+		testBucket("DC1", "C3", "Level", "2"),
+		testBucket("DC2", "C4", "Level", "2"),
+		testBucket("DC3", "C5", "Level", "2"),
+	}
+	levels := []TreeLevel{{Key: []string{"1"}, Buckets: parents}, {Key: []string{"2"}, Buckets: children}}
+	tests := []struct {
+		name string
+		def  model.XDCRDef
+		want []string
+	}{
+		{"round-robin", model.XDCRDef{}, []string{"P1->C1", "P2->C2", "P1->C3", "P2->C4", "P1->C5"}},
+		{"balanced", model.XDCRDef{FanOut: model.BalancedFanOut}, []string{"P1->C1", "P2->C2", "P1->C3", "P2->C4", "P1->C5"}},
+		// C5 has no parent in its datacenter, it falls back to the parent with the lowest fan-out
+		{"affinity", model.XDCRDef{FanOut: model.AffinityFanOut}, []string{"P1->C1", "P1->C2", "P1->C3", "P2->C4", "P2->C5"}},
+		{"affinity labels", model.XDCRDef{FanOut: model.BalancedFanOut, Affinity: []string{"Datacenter"}}, []string{"P1->C1", "P1->C2", "P1->C3", "P2->C4", "P2->C5"}},
+		{"round-robin with affinity", model.XDCRDef{Affinity: []string{"Datacenter"}}, []string{"P1->C1", "P1->C2", "P1->C3", "P2->C4", "P1->C5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, l := range TreeLinks(levels, tt.def) {
+				got = append(got, l.Parent.Name+"->"+l.Child.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TreeLinks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	buckets := model.BucketByPath{
+		testBucket("DC1", "A", "Level", "1"),
+		testBucket("DC2", "B", "Level", "2"),
+		testBucket("DC3", "C", "Level", "10"),
+	}
+	if got, want := edges(buildTree(buckets, model.XDCRDef{}, false)), []string{"A->B", "B->C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buildTree() = %v, want %v", got, want)
+	}
+	if got, want := edges(buildTree(buckets, model.XDCRDef{}, true)), []string{"B->A", "C->B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("buildTree(up) = %v, want %v", got, want)
+	}
+}
+
+func TestComparePaths(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"DC1_CG_CBBOX_2_Rbox", "DC1_CG_CBBOX_10_Rbox", -1},
+		{"DC10_CG_CBBOX_0_Rbox", "DC2_CG_CBBOX_0_Rbox", 1},
+		{"DC1_CG_CBBOX_02_Rbox", "DC1_CG_CBBOX_2_Rbox", 0},
+		{"DC1_CG_CBBOX_2_Rbox", "DC1_CG_CBBOX_2_Stat", -1},
+		{"DC1_CG_CBBOX_2", "DC1_CG_CBBOX_2_Rbox", -1},
+		{"DC1_CG_CBBOX_B", "DC1_CG_CBBOX_10", 1},
+	}
+	for _, tt := range tests {
+		if got := comparePaths(tt.a, tt.b); got != tt.want {
+			t.Errorf("comparePaths(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTreeLevelsInstanceOrder(t *testing.T) {
+	buckets := model.BucketByPath{}
+	for _, instance := range []string{"0", "1", "10", "11", "2"} {
+		b := testBucket("DC1", "Rbox", "Level", "2", "Cluster", "CBBOX_"+instance)
+		buckets = append(buckets, b)
+	}
+	buckets = append(buckets, testBucket("DC1", "Top", "Level", "1"), testBucket("DC2", "Top", "Level", "1"))
+	levels := TreeLevels(buckets, model.XDCRDef{})
+	got := []string{}
+	for _, b := range levels[1].Buckets {
+		got = append(got, b.Labels["Cluster"])
+	}
+	if want := []string{"CBBOX_0", "CBBOX_1", "CBBOX_2", "CBBOX_10", "CBBOX_11"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TreeLevels() buckets = %v, want %v", got, want)
+	}
+	// the round-robin parents follow the instances
+	parents := []string{}
+	for _, l := range TreeLinks(levels, model.XDCRDef{}) {
+		parents = append(parents, l.Parent.Labels["Datacenter"])
+	}
+	if want := []string{"DC1", "DC2", "DC1", "DC2", "DC1"}; !reflect.DeepEqual(parents, want) {
+		t.Errorf("TreeLinks() parents = %v, want %v", parents, want)
+	}
+}
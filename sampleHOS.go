@@ -1,90 +1,93 @@
 package main
 
 import (
-	"bytes"
-	"fmt"
+	"io"
 	"os"
+
+	"github.com/dbenque/couchbaseblueprint/expansion"
+	"github.com/dbenque/couchbaseblueprint/model"
+	"github.com/dbenque/couchbaseblueprint/render"
+	"github.com/dbenque/couchbaseblueprint/rules"
 )
 
-func gen_sample1() {
+func gen_sample1(w io.Writer, r render.Renderer) error {
 
 	os.Remove("sample1")
 	os.Mkdir("sample1", 0777)
 
-	DC1 := NewDatacenter("DC1")
-	DC2 := NewDatacenter("DC2")
+	DC1 := model.NewDatacenter("DC1")
+	DC2 := model.NewDatacenter("DC2")
 	def1 := Def1()
 
-	ToFile(ClusterGroupDefBluePrint{[]ClusterGroupDef{def1}}, "sample1/couchbase")
+	if err := expansion.ToFile(model.ClusterGroupDefBluePrint{Header: expansion.NewHeader(expansion.TopologyKind), ClusterGroups: []model.ClusterGroupDef{def1}}, "sample1/couchbase"); err != nil {
+		return err
+	}
 
 	DC1.AddClusterGroupDef(def1)
 	DC2.AddClusterGroupDef(def1)
 
-	var buf bytes.Buffer
-	DC1.Dot(&buf)
-	DC2.Dot(&buf)
-
-	xdcrdefs := []XDCRDef{}
+	xdcrdefs := []model.XDCRDef{}
 	xdcrdefs = append(xdcrdefs, Def1XDCR_Hyatt())
 	xdcrdefs = append(xdcrdefs, Def1XDCR_HyattR())
 	xdcrdefs = append(xdcrdefs, Def1XDCR_Campanile())
 
-	ToFile(XDCRDefBluePrint{xdcrdefs}, "sample1/XDCR")
+	if err := expansion.ToFile(model.XDCRDefBluePrint{Header: expansion.NewHeader(expansion.XDCRKind), XDCRDefs: xdcrdefs}, "sample1/XDCR"); err != nil {
+		return err
+	}
 
+	xdcrs := []model.XDCR{}
 	for _, xdcr := range xdcrdefs {
-		for _, x := range NewXDCR(xdcr, []Datacenter{DC1, DC2}) {
-			x.Dot(&buf)
-		}
+		xdcrs = append(xdcrs, rules.NewXDCR(xdcr, []model.Datacenter{DC1, DC2})...)
 	}
 
-	fmt.Printf("digraph { \n%s\n}\n", buf.String())
+	return r.Render(w, []model.Datacenter{DC1, DC2}, xdcrs)
 }
 
-func Def1() ClusterGroupDef {
-	return ClusterGroupDef{
+func Def1() model.ClusterGroupDef {
+	return model.ClusterGroupDef{
 		Name:       "CG",
 		PeakTokens: []string{"PK1", "PK2"},
-		ClusterDefs: []ClusterDef{
+		ClusterDefs: []model.ClusterDef{
 			{Name: "Booking", Instances: []string{"A", "B"},
-				Buckets: []Bucket{
-					{Name: "Hyatt", Labels: Labels{"Company": "Hyatt"}},
-					{Name: "HyattR", Labels: Labels{"Company": "Hyatt", "ReadOnly": "true"}},
-					{Name: "Campanile", Labels: Labels{"Company": "Campanile"}},
+				Buckets: []model.Bucket{
+					{Name: "Hyatt", Labels: model.Labels{"Company": "Hyatt"}},
+					{Name: "HyattR", Labels: model.Labels{"Company": "Hyatt", "ReadOnly": "true"}},
+					{Name: "Campanile", Labels: model.Labels{"Company": "Campanile"}},
 				},
 			}},
 	}
 }
 
-func Def1XDCR_Hyatt() XDCRDef {
-	return XDCRDef{
+func Def1XDCR_Hyatt() model.XDCRDef {
+	return model.XDCRDef{
 		Rule:          "ring",
 		Bidirectional: false,
-		Source:        Selector{"Company": "Hyatt"},
-		SourceExclude: Selector{"ReadOnly": "true"},
+		Source:        model.Selector{"Company": "Hyatt"},
+		SourceExclude: model.Selector{"ReadOnly": "true"},
 		GroupOn:       []string{},
 		Args:          []string{},
 		Color:         "red",
 	}
 }
 
-func Def1XDCR_HyattR() XDCRDef {
-	return XDCRDef{
+func Def1XDCR_HyattR() model.XDCRDef {
+	return model.XDCRDef{
 		Rule:          "custom",
 		Bidirectional: true,
-		Source:        Selector{"Company": "Hyatt"},
-		SourceExclude: Selector{"ReadOnly": "true"},
-		Destination:   Selector{"Company": "Hyatt", "ReadOnly": "true"},
+		Source:        model.Selector{"Company": "Hyatt"},
+		SourceExclude: model.Selector{"ReadOnly": "true"},
+		Destination:   model.Selector{"Company": "Hyatt", "ReadOnly": "true"},
 		GroupOn:       []string{"Cluster", "ClusterGroup", "Datacenter"},
 		Args:          []string{},
 		Color:         "blue",
 	}
 }
 
-func Def1XDCR_Campanile() XDCRDef {
-	return XDCRDef{
+func Def1XDCR_Campanile() model.XDCRDef {
+	return model.XDCRDef{
 		Rule:          "ring",
 		Bidirectional: false,
-		Source:        Selector{"Company": "Campanile"},
+		Source:        model.Selector{"Company": "Campanile"},
 		GroupOn:       []string{"Datacenter", "ClusterGroup"},
 		Args:          []string{},
 		Color:         "green",
@@ -1,27 +1,31 @@
 package main
 
 import (
-	"bytes"
-	"fmt"
+	"io"
 	"os"
+
+	"github.com/dbenque/couchbaseblueprint/expansion"
+	"github.com/dbenque/couchbaseblueprint/model"
+	"github.com/dbenque/couchbaseblueprint/render"
+	"github.com/dbenque/couchbaseblueprint/rules"
 )
 
-func gen_RBox1() {
+func gen_RBox1(w io.Writer, r render.Renderer) error {
 
 	os.Remove("RBox1")
 	os.Mkdir("RBox1", 0777)
 
-	ADP := NewDatacenter("ADP")
+	ADP := model.NewDatacenter("ADP")
 
-	RBLH1 := NewDatacenter("RB_LH_1")
-	RBLH2 := NewDatacenter("RB_LH_2")
-	RBLH3 := NewDatacenter("RB_LH_3")
-	RBLH4 := NewDatacenter("RB_LH_4")
-	RBLHB := NewDatacenter("RB_LH_B")
+	RBLH1 := model.NewDatacenter("RB_LH_1")
+	RBLH2 := model.NewDatacenter("RB_LH_2")
+	RBLH3 := model.NewDatacenter("RB_LH_3")
+	RBLH4 := model.NewDatacenter("RB_LH_4")
+	RBLHB := model.NewDatacenter("RB_LH_B")
 
-	RBAF1 := NewDatacenter("RB_AF_1")
-	RBAF2 := NewDatacenter("RB_AF_2")
-	RBAFB := NewDatacenter("RB_AF_B")
+	RBAF1 := model.NewDatacenter("RB_AF_1")
+	RBAF2 := model.NewDatacenter("RB_AF_2")
+	RBAFB := model.NewDatacenter("RB_AF_B")
 
 	def_LH := DefRBox("LH")
 	def_AF := DefRBox("AF")
@@ -29,14 +33,16 @@ func gen_RBox1() {
 	defB_AF := DefRBoxB("AF")
 	defM := DefRBoxM()
 
-	defs := []ClusterGroupDef{}
+	defs := []model.ClusterGroupDef{}
 	defs = append(defs, def_LH)
 	defs = append(defs, def_AF)
 	defs = append(defs, defB_LH)
 	defs = append(defs, defB_AF)
 	defs = append(defs, defM)
 
-	ToFile(ClusterGroupDefBluePrint{defs}, "RBox1/couchbase")
+	if err := expansion.ToFile(model.ClusterGroupDefBluePrint{Header: expansion.NewHeader(expansion.TopologyKind), ClusterGroups: defs}, "RBox1/couchbase"); err != nil {
+		return err
+	}
 
 	ADP.AddClusterGroupDef(defM)
 
@@ -51,92 +57,87 @@ func gen_RBox1() {
 	RBAF1.AddClusterGroupDef(def_AF)
 	RBAF2.AddClusterGroupDef(def_AF)
 
-	DCs := []Datacenter{ADP, RBAFB, RBAF1, RBAF2, RBLHB, RBLH1, RBLH2, RBLH3, RBLH4}
-
-	var buf bytes.Buffer
+	DCs := []model.Datacenter{ADP, RBAFB, RBAF1, RBAF2, RBLHB, RBLH1, RBLH2, RBLH3, RBLH4}
 
-	for _, d := range DCs {
-		d.Dot(&buf)
-	}
-
-	xdcrdefs := []XDCRDef{}
+	xdcrdefs := []model.XDCRDef{}
 	xdcrdefs = append(xdcrdefs, DefXDCR_M())
 	xdcrdefs = append(xdcrdefs, DefXDCR_B())
 
-	ToFile(XDCRDefBluePrint{xdcrdefs}, "RBox1/XDCR")
+	if err := expansion.ToFile(model.XDCRDefBluePrint{Header: expansion.NewHeader(expansion.XDCRKind), XDCRDefs: xdcrdefs}, "RBox1/XDCR"); err != nil {
+		return err
+	}
 
+	xdcrs := []model.XDCR{}
 	for _, xdcr := range xdcrdefs {
-		for _, x := range NewXDCR(xdcr, DCs) {
-			x.Dot(&buf)
-		}
+		xdcrs = append(xdcrs, rules.NewXDCR(xdcr, DCs)...)
 	}
 
-	fmt.Printf("digraph { \n%s\n}\n", buf.String())
+	return r.Render(w, DCs, xdcrs)
 }
 
-func DefRBox(pk string) ClusterGroupDef {
-	return ClusterGroupDef{
+func DefRBox(pk string) model.ClusterGroupDef {
+	return model.ClusterGroupDef{
 		Name:       "CG",
 		PeakTokens: []string{pk},
-		ClusterDefs: []ClusterDef{
+		ClusterDefs: []model.ClusterDef{
 			{Name: "CBBOX", Instances: []string{""},
-				Buckets: []Bucket{
-					{Name: "Rbox", Labels: Labels{"Role": "Rbox", "Type": "Child"}},
-					{Name: "SBox", Labels: Labels{"Role": "SBox", "Type": "Child"}},
-					{Name: "Stat", Labels: Labels{"Role": "Stat", "Type": "Child"}},
+				Buckets: []model.Bucket{
+					{Name: "Rbox", Labels: model.Labels{"Role": "Rbox", "Type": "Child"}},
+					{Name: "SBox", Labels: model.Labels{"Role": "SBox", "Type": "Child"}},
+					{Name: "Stat", Labels: model.Labels{"Role": "Stat", "Type": "Child"}},
 				},
 			}},
 	}
 }
 
-func DefRBoxB(pk string) ClusterGroupDef {
-	return ClusterGroupDef{
+func DefRBoxB(pk string) model.ClusterGroupDef {
+	return model.ClusterGroupDef{
 		Name:       "CG",
 		PeakTokens: []string{pk},
-		ClusterDefs: []ClusterDef{
+		ClusterDefs: []model.ClusterDef{
 			{Name: "CBBOX", Instances: []string{""},
-				Buckets: []Bucket{
-					{Name: "Rbox", Labels: Labels{"Role": "Rbox", "Type": "BCast"}},
-					{Name: "SBox", Labels: Labels{"Role": "SBox", "Type": "BCast"}},
-					{Name: "Stat", Labels: Labels{"Role": "Stat", "Type": "BCast"}},
+				Buckets: []model.Bucket{
+					{Name: "Rbox", Labels: model.Labels{"Role": "Rbox", "Type": "BCast"}},
+					{Name: "SBox", Labels: model.Labels{"Role": "SBox", "Type": "BCast"}},
+					{Name: "Stat", Labels: model.Labels{"Role": "Stat", "Type": "BCast"}},
 				},
 			}},
 	}
 }
 
-func DefRBoxM() ClusterGroupDef {
-	return ClusterGroupDef{
+func DefRBoxM() model.ClusterGroupDef {
+	return model.ClusterGroupDef{
 		Name:       "CG",
 		PeakTokens: []string{""},
-		ClusterDefs: []ClusterDef{
+		ClusterDefs: []model.ClusterDef{
 			{Name: "CBBOX", Instances: []string{""},
-				Buckets: []Bucket{
-					{Name: "Rbox", Labels: Labels{"Role": "Rbox", "Type": "MCast"}},
-					{Name: "SBox", Labels: Labels{"Role": "SBox", "Type": "MCast"}},
-					{Name: "Stat", Labels: Labels{"Role": "Stat", "Type": "MCast"}},
+				Buckets: []model.Bucket{
+					{Name: "Rbox", Labels: model.Labels{"Role": "Rbox", "Type": "MCast"}},
+					{Name: "SBox", Labels: model.Labels{"Role": "SBox", "Type": "MCast"}},
+					{Name: "Stat", Labels: model.Labels{"Role": "Stat", "Type": "MCast"}},
 				},
 			}},
 	}
 }
 
-func DefXDCR_M() XDCRDef {
-	return XDCRDef{
+func DefXDCR_M() model.XDCRDef {
+	return model.XDCRDef{
 		Rule:          "custom",
 		Bidirectional: false,
-		Source:        Selector{"Type": "MCast"},
-		Destination:   Selector{"Type": "BCast"},
+		Source:        model.Selector{"Type": "MCast"},
+		Destination:   model.Selector{"Type": "BCast"},
 		GroupOn:       []string{"Role"},
 		Args:          []string{},
 		Color:         "red",
 	}
 }
 
-func DefXDCR_B() XDCRDef {
-	return XDCRDef{
+func DefXDCR_B() model.XDCRDef {
+	return model.XDCRDef{
 		Rule:          "custom",
 		Bidirectional: false,
-		Source:        Selector{"Type": "BCast"},
-		Destination:   Selector{"Type": "Child"},
+		Source:        model.Selector{"Type": "BCast"},
+		Destination:   model.Selector{"Type": "Child"},
 		GroupOn:       []string{"Role", "ClusterGroup"},
 		Args:          []string{},
 		Color:         "blue",
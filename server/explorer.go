@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/dbenque/couchbaseblueprint/expansion"
+	"github.com/dbenque/couchbaseblueprint/model"
+	"github.com/dbenque/couchbaseblueprint/render"
+	"github.com/gorilla/mux"
+)
+
+// ExplorerGraph is the Cytoscape graph of a topology version, each edge referencing the definition that produced it.
+type ExplorerGraph struct {
+	render.CytoscapeGraph
+	Definitions []model.XDCRDef `json:"definitions"`
+}
+
+func NewExplorerGraph(bp *expansion.Blueprint) ExplorerGraph {
+	g := ExplorerGraph{CytoscapeGraph: render.NewCytoscapeGraph(bp.Datacenters, nil), Definitions: []model.XDCRDef{}}
+	for _, set := range bp.XDCRSets {
+		for i, xdcrs := range set.Replications() {
+			index := len(g.Definitions)
+			g.Definitions = append(g.Definitions, set.Definitions[i])
+			g.addDefinitionEdges(xdcrs, index)
+		}
+	}
+	return g
+}
+
+// addDefinitionEdges adds the replications produced by the index-th definition, styled as the cytoscape renderer does.
+func (g *ExplorerGraph) addDefinitionEdges(xdcrs []model.XDCR, index int) {
+	for _, e := range render.NewCytoscapeGraph(nil, xdcrs).Elements.Edges {
+		e.Data.ID = fmt.Sprintf("e%d", len(g.Elements.Edges))
+		if e.Data.Color == "" {
+			e.Data.Color = "black"
+		}
+		e.Data.Definition = &index
+		g.Elements.Edges = append(g.Elements.Edges, e)
+	}
+}
+
+func dcExplorerPage(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	data := struct {
+		User           string
+		DatacenterName string
+		Version        string
+	}{
+		User:           mux.Vars(r)["user"],
+		DatacenterName: mux.Vars(r)["dcname"],
+		Version:        r.Form.Get("v"),
+	}
+	renderTemplate(w, "explorer", data)
+}
+
+func dcExplorerGraph(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	user := mux.Vars(r)["user"]
+	datacenterName := mux.Vars(r)["dcname"]
+
+	dir, err := versionDirectory(user, datacenterName, r.Form.Get("v"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	bp, errDc := loadVersion(dir, datacenterName)
+	if errDc != nil {
+		http.Error(w, errDc.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(NewExplorerGraph(bp))
+}
+
+func dcExplain(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	user := mux.Vars(r)["user"]
+	datacenterName := mux.Vars(r)["dcname"]
+
+	dir, err := versionDirectory(user, datacenterName, r.Form.Get("v"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	bp, errDc := loadVersion(dir, datacenterName)
+	if errDc != nil {
+		http.Error(w, errDc.Error(), http.StatusNotFound)
+		return
+	}
+	e, err := expansion.Explain(bp, r.Form.Get("source"), r.Form.Get("destination"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
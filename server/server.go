@@ -0,0 +1,609 @@
+// Package server is the web interface used to upload, browse and explore datacenter topologies.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dbenque/couchbaseblueprint/expansion"
+	"github.com/dbenque/couchbaseblueprint/model"
+	"github.com/dbenque/couchbaseblueprint/render"
+	"github.com/dbenque/couchbaseblueprint/report"
+	"github.com/gorilla/mux"
+
+	"net/http"
+)
+
+var templates *template.Template
+
+// Base names of the files stored with a version, their extension is the one of their format.
+const (
+	topoFile   = "topodef"
+	xdcrFile   = "xdcrdef"
+	policyFile = "policy"
+	styleFile  = "style"
+)
+
+// Serve starts the web server on addr, the templates and static files are read from the public folder.
+func Serve(addr string) error {
+	templates = template.Must(template.New("abc").Funcs(fns).ParseGlob("public/template/*.html"))
+	r := mux.NewRouter()
+	r.HandleFunc("/main", mainPage)
+	r.HandleFunc("/users", usersPage)
+	r.HandleFunc("/topo", dcTopoPageForm)
+	r.HandleFunc("/datacenters", datacentersPage)
+	r.HandleFunc("/datacenter/{datacenterName}", dcPage)
+	r.HandleFunc("/newdatacenter", newDatacenterPage)
+	r.HandleFunc("/topo/{user}/datacenter/{datacenterName}", dcTopoPage)
+	r.HandleFunc("/uploadTopo/{user}/datacenter/{dcname}", dcUploadTopo)
+	r.HandleFunc("/graph/{user}/datacenter/{dcname}", dcTopoGraph)
+	r.HandleFunc("/explore/{user}/datacenter/{dcname}", dcExplorerPage)
+	r.HandleFunc("/explore/{user}/datacenter/{dcname}/graph.json", dcExplorerGraph)
+	r.HandleFunc("/explain/{user}/datacenter/{dcname}", dcExplain)
+	r.HandleFunc("/report/{user}/datacenter/{dcname}", dcReport)
+	r.HandleFunc("/schema/{name}", schemaHandler)
+	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./public/")))
+	http.Handle("/", r)
+	return http.ListenAndServe(addr, nil)
+}
+
+var fns = template.FuncMap{
+	"ImgPath": func(user, datacenterName, version string) string {
+		if version != "" {
+			return filepath.Join(datacenterURI(user, datacenterName), "v"+version, "topo.png")
+		}
+		if lv, err := latestVersion(datacenterDirectory(user, datacenterName)); err == nil {
+			return filepath.Join(datacenterURI(user, datacenterName), lv, "topo.png")
+		}
+		return ""
+	},
+	"ListUsers": func() []string {
+		return listUsers()
+	},
+	"RenderFormatNames": render.RenderFormatNames,
+}
+
+func renderTemplate(w http.ResponseWriter, tmpl string, data interface{}) {
+	err := templates.ExecuteTemplate(w, tmpl, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func mainPage(w http.ResponseWriter, r *http.Request) {
+	user := ""
+	//check cookie
+	if c, err := r.Cookie("user"); err == nil {
+		user = c.Value
+	}
+	//check uri
+	r.ParseForm()
+	userURL := r.Form.Get("user")
+	if userURL != "" {
+		user = userURL
+		expiration := time.Now().Add(24 * time.Hour)
+		cookie := http.Cookie{Name: "user", Value: user, Expires: expiration}
+		http.SetCookie(w, &cookie)
+	}
+	data := struct {
+		User string
+	}{
+		User: user,
+	}
+	renderTemplate(w, "mainPage", data)
+}
+
+func usersPage(w http.ResponseWriter, r *http.Request) {
+	renderTemplate(w, "users", nil)
+}
+
+func getuser(r *http.Request) string {
+	user := ""
+	//check cookie
+	if c, err := r.Cookie("user"); err == nil {
+		user = c.Value
+	}
+	return user
+}
+
+func datacentersPage(w http.ResponseWriter, r *http.Request) {
+	user := getuser(r)
+	if user == "" {
+		http.Redirect(w, r, "/main", http.StatusTemporaryRedirect)
+		return
+	}
+	folderPath := filepath.Join("public", "data", user, "dc")
+	files, _ := ioutil.ReadDir(folderPath)
+	type dc struct {
+		User string
+		Name string
+	}
+
+	data := struct {
+		User        string
+		Datacenters []dc
+	}{
+		User:        user,
+		Datacenters: []dc{},
+	}
+	if files != nil {
+		for _, file := range files {
+			data.Datacenters = append(data.Datacenters, dc{Name: file.Name()})
+		}
+	}
+	renderTemplate(w, "datacenters", data)
+}
+
+func newDatacenterPage(w http.ResponseWriter, r *http.Request) {
+	user := getuser(r)
+	if user == "" {
+		http.Redirect(w, r, "/main", http.StatusTemporaryRedirect)
+		return
+	}
+	r.ParseForm()
+	d := r.Form.Get("datacenterName")
+	http.Redirect(w, r, "/topo/"+user+"/datacenter/"+d, http.StatusMovedPermanently)
+}
+
+func dcPage(w http.ResponseWriter, r *http.Request) {
+	user := getuser(r)
+	if user == "" {
+		http.Redirect(w, r, "/main", http.StatusTemporaryRedirect)
+		return
+	}
+	d := mux.Vars(r)["datacenterName"]
+	http.Redirect(w, r, "/topo/"+user+"/datacenter/"+d, http.StatusMovedPermanently)
+}
+
+func dcTopoPageForm(w http.ResponseWriter, r *http.Request) {
+	u := mux.Vars(r)["user"]
+	d := mux.Vars(r)["datacenterName"]
+	fmt.Printf("USer %s, datacenter %s\n", u, d)
+
+	http.Redirect(w, r, "/topo/"+u+"/datacenter/"+d, http.StatusMovedPermanently)
+}
+
+// topoPage is the data of the topoDC template.
+type topoPage struct {
+	User           string
+	DatacenterName string
+	Versions       []int
+	Version        string
+	// TopoFile is the name of the topology file of the version
+	TopoFile    string
+	Diagnostics []expansion.Problem
+	Violations  []expansion.Violation
+	PolicyError string
+}
+
+func newTopoPage(user, datacenterName, version string) topoPage {
+	versions, _ := listVersions(datacenterDirectory(user, datacenterName))
+	if version == "" && versions != nil && len(version) > 0 {
+		version = strconv.Itoa(versions[len(versions)-1])
+	}
+	return topoPage{User: user, DatacenterName: datacenterName, Versions: versions, Version: version}
+}
+
+func dcTopoPage(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	data := newTopoPage(mux.Vars(r)["user"], mux.Vars(r)["datacenterName"], r.Form.Get("v"))
+
+	if dir, err := versionDirectory(data.User, data.DatacenterName, data.Version); err == nil {
+		if path, err := expansion.FindFile(dir, topoFile, ""); err == nil {
+			data.TopoFile = filepath.Base(path)
+		}
+		if bp, err := loadVersion(dir, data.DatacenterName); err == nil {
+			data.Diagnostics = expansion.Validate(bp)
+		}
+		if data.Violations, err = checkVersionPolicies(dir, data.DatacenterName); err != nil {
+			data.PolicyError = err.Error()
+		}
+	}
+	renderTemplate(w, "topoDC", data)
+}
+
+// uploadFiles maps the form fields of the upload to the base name of the file they are stored in a version.
+var uploadFiles = []struct {
+	Field    string
+	Base     string
+	Required bool
+}{
+	{Field: "file", Base: topoFile, Required: true},
+	{Field: "xdcr", Base: xdcrFile},
+	{Field: "policy", Base: policyFile},
+	{Field: "style", Base: styleFile},
+}
+
+func dcUploadTopo(w http.ResponseWriter, r *http.Request) {
+	user := mux.Vars(r)["user"]
+	datacenterName := mux.Vars(r)["dcname"]
+
+	// Prepare Folder for next topo
+	var perm os.FileMode = 0777
+	dirDc := datacenterDirectory(user, datacenterName)
+	os.MkdirAll(dirDc, perm)
+	version, err := nextVersion(dirDc)
+	if err != nil {
+		uploadProblems(w, user, datacenterName, http.StatusInternalServerError, expansion.Problem{Index: -1, Message: err.Error()})
+		return
+	}
+	dir := filepath.Join(dirDc, version)
+	os.MkdirAll(dir, perm)
+
+	// Copy the uploaded files, remembering their names for the diagnostics
+	uploaded := map[string]string{}
+	for _, f := range uploadFiles {
+		path, name, err := saveFormFile(r, f.Field, dir, f.Base)
+		if err != nil {
+			os.RemoveAll(dir)
+			uploadProblems(w, user, datacenterName, http.StatusInternalServerError, expansion.Problem{Subject: "upload", Index: -1, Message: fmt.Sprintf("the file of the field %q can not be stored: %v", f.Field, err)})
+			return
+		}
+		if name == "" && f.Required {
+			os.RemoveAll(dir)
+			uploadProblems(w, user, datacenterName, http.StatusBadRequest, expansion.Problem{Subject: "upload", Index: -1, Message: fmt.Sprintf("no file uploaded in the required field %q", f.Field)})
+			return
+		}
+		uploaded[path] = name
+	}
+
+	// creation of VDatacenter topology target
+	bp, errDc := loadVersion(dir, datacenterName)
+	if errDc == nil {
+		_, errDc = loadVersionPolicies(dir)
+	}
+	var style *model.StyleBluePrint
+	if errDc == nil {
+		style, errDc = loadVersionStyle(dir)
+	}
+	if errDc != nil {
+		// the version is not kept, the diagnostics are shown on the upload page
+		os.RemoveAll(dir)
+		fe, ok := errDc.(*expansion.FileError)
+		if !ok {
+			fe = &expansion.FileError{Problems: []expansion.Problem{{Index: -1, Message: errDc.Error()}}}
+		}
+		for i, p := range fe.Problems {
+			if name, ok := uploaded[p.File]; ok {
+				fe.Problems[i].File = name
+			}
+		}
+		uploadProblems(w, user, datacenterName, http.StatusBadRequest, fe.Problems...)
+		return
+	}
+
+	//write json and yaml topo files
+	expansion.ToFile(bp.Datacenters[0], filepath.Join(dir, "topo"))
+
+	//write dot topo file
+	var buf bytes.Buffer
+	o := render.DefaultOptions()
+	o.Style = style
+	render.DotRenderer{Options: &o}.RenderStream(&buf, bp.Datacenters, bp.EachXDCR)
+	ioutil.WriteFile(filepath.Join(dir, "topo.dot"), buf.Bytes(), 0777)
+
+	//process dot file to build image
+	cmd := exec.Command("dot", "-Tpng", "-o"+filepath.Join(dir, "topo.png"), filepath.Join(dir, "topo.dot"))
+	var out bytes.Buffer
+	var outerr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &outerr
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(dir)
+		message := fmt.Sprintf("the graph image can not be built by graphviz: %v", err)
+		if stderr := strings.TrimSpace(outerr.String()); stderr != "" {
+			message += ": " + stderr
+		}
+		uploadProblems(w, user, datacenterName, http.StatusInternalServerError, expansion.Problem{Subject: "dot", Index: -1, Message: message})
+		return
+	}
+	http.Redirect(w, r, "/topo/"+user+"/datacenter/"+datacenterName, http.StatusMovedPermanently)
+}
+
+// uploadProblems shows the upload page with the problems that prevented the upload of a version.
+func uploadProblems(w http.ResponseWriter, user, datacenterName string, status int, problems ...expansion.Problem) {
+	data := newTopoPage(user, datacenterName, "")
+	data.Diagnostics = problems
+	w.WriteHeader(status)
+	renderTemplate(w, "topoDC", data)
+}
+
+// saveFormFile stores the file uploaded in field as base in dir, with the extension of its format. It returns the
+// stored path and the uploaded name, empty when nothing was uploaded.
+func saveFormFile(r *http.Request, field, dir, base string) (string, string, error) {
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		return "", "", nil
+	}
+	defer file.Close()
+	b, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", "", err
+	}
+	path := filepath.Join(dir, base+expansion.FormatExtension(expansion.DetectFormat(header.Filename, b)))
+	if err := ioutil.WriteFile(path, b, 0777); err != nil {
+		return "", "", err
+	}
+	return path, header.Filename, nil
+}
+
+// loadVersionPolicies reads the policy file stored with a version, if any.
+func loadVersionPolicies(dir string) ([]expansion.Policy, error) {
+	path, err := expansion.FindFile(dir, policyFile, "")
+	if err != nil {
+		return nil, nil
+	}
+	return expansion.PoliciesFromFile(path)
+}
+
+// loadVersionStyle reads the style file stored with a version, if any.
+func loadVersionStyle(dir string) (*model.StyleBluePrint, error) {
+	path, err := expansion.FindFile(dir, styleFile, "")
+	if err != nil {
+		return nil, nil
+	}
+	return expansion.StyleFromFile(path)
+}
+
+// checkVersionPolicies evaluates the policy file stored with a version, if any.
+func checkVersionPolicies(dir, datacenterName string) ([]expansion.Violation, error) {
+	policies, err := loadVersionPolicies(dir)
+	if err != nil || policies == nil {
+		return nil, err
+	}
+	bp, err := loadVersion(dir, datacenterName)
+	if err != nil {
+		return nil, err
+	}
+	return expansion.CheckPolicies(bp, policies), nil
+}
+
+func schemaHandler(w http.ResponseWriter, r *http.Request) {
+	s, err := expansion.Schema(mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	json.NewEncoder(w).Encode(s)
+}
+
+func dcReport(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	user := mux.Vars(r)["user"]
+	datacenterName := mux.Vars(r)["dcname"]
+
+	format := r.Form.Get("format")
+	if format == "" {
+		format = "html"
+	}
+	contentType, ok := report.Formats[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown report format %q, expected markdown or html", format), http.StatusBadRequest)
+		return
+	}
+
+	dir, err := versionDirectory(user, datacenterName, r.Form.Get("v"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	bp, err := loadVersion(dir, datacenterName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	policies, err := loadVersionPolicies(dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rep, err := report.New(fmt.Sprintf("%s %s", datacenterName, filepath.Base(dir)), bp, policies)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := rep.Write(&buf, format); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(buf.Bytes())
+}
+
+func dcTopoGraph(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	user := mux.Vars(r)["user"]
+	datacenterName := mux.Vars(r)["dcname"]
+
+	format := r.Form.Get("format")
+	if format == "" {
+		format = "dot"
+	}
+	out, err := render.GetRenderFormat(format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dir, err := versionDirectory(user, datacenterName, r.Form.Get("v"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	bp, errDc := loadVersion(dir, datacenterName)
+	if errDc != nil {
+		http.Error(w, errDc.Error(), http.StatusNotFound)
+		return
+	}
+
+	view, err := graphView(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// the physical graph aggregates the replications of the view per pair of clusters or datacenters
+	physical := model.BoxKind(r.Form.Get("physical"))
+	collapse := view.Collapse
+	if physical != "" {
+		if physical != model.ClusterBox && physical != model.DatacenterBox {
+			http.Error(w, fmt.Sprintf("Invalid physical level %q, expected one of %v", physical, expansion.PhysicalLevels), http.StatusBadRequest)
+			return
+		}
+		if collapse != "" {
+			http.Error(w, "Invalid collapse, the physical graph is already folded", http.StatusBadRequest)
+			return
+		}
+		collapse = physical
+	}
+	renderer, err := graphRenderer(out.Renderer, r, dir, collapse)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if view.IsZero() && physical == "" {
+		w.Header().Set("Content-Type", out.ContentType)
+		render.RenderStream(renderer, w, bp.Datacenters, bp.EachXDCR)
+		return
+	}
+	dcs, xdcrs, err := view.Apply(bp)
+	if err == nil && physical != "" {
+		dcs, xdcrs, err = expansion.PhysicalGraph(dcs, xdcrs, physical)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", out.ContentType)
+	renderer.Render(w, dcs, xdcrs)
+}
+
+// graphView reads the view of the graph from the query parameters select, definitions, collapse, focus and hops.
+func graphView(r *http.Request) (expansion.View, error) {
+	hops := 1
+	if h := r.Form.Get("hops"); h != "" {
+		n, err := strconv.Atoi(h)
+		if err != nil {
+			return expansion.View{}, fmt.Errorf("Invalid hops %q, expected a number", h)
+		}
+		hops = n
+	}
+	return expansion.ParseView(r.Form.Get("select"), r.Form.Get("definitions"), r.Form.Get("collapse"), r.Form.Get("focus"), hops)
+}
+
+// graphRenderer configures the renderer with the query parameters rankBy, direction, labels and colors, with the
+// style file stored in the version folder and with the level folded by the view.
+func graphRenderer(renderer render.Renderer, r *http.Request, dir string, collapse model.BoxKind) (render.Renderer, error) {
+	o := render.DefaultOptions()
+	o.Collapse = collapse
+	if rankBy, ok := r.Form["rankBy"]; ok {
+		o.RankBy = rankBy[0]
+	}
+	o.Direction = r.Form.Get("direction")
+	o.ShowLabels = r.Form.Get("labels") == "true"
+	if colors := r.Form.Get("colors"); colors != "" {
+		o.ClusterColors = strings.Split(colors, ",")
+	}
+	style, err := loadVersionStyle(dir)
+	if err != nil {
+		return nil, err
+	}
+	o.Style = style
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return render.WithOptions(renderer, o), nil
+}
+
+// versionDirectory returns the folder of the requested version, or of the latest one if version is empty.
+func versionDirectory(user, datacenterName, version string) (string, error) {
+	dirDc := datacenterDirectory(user, datacenterName)
+	if version != "" {
+		return filepath.Join(dirDc, "v"+version), nil
+	}
+	lv, err := latestVersion(dirDc)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dirDc, lv), nil
+}
+
+// loadVersion expands the topology stored in a version folder and reads its optional XDCR definitions.
+func loadVersion(dir, datacenterName string) (*expansion.Blueprint, error) {
+	topoPath, err := expansion.FindFile(dir, topoFile, "")
+	if err != nil {
+		return nil, err
+	}
+	// the XDCR file is optional
+	xdcrPath, _ := expansion.FindFile(dir, xdcrFile, "")
+	return expansion.BlueprintFromFiles(topoPath, xdcrPath, []model.Datacenter{model.NewDatacenter(datacenterName)})
+}
+
+func datacenterURI(user, datacenterName string) string {
+	return filepath.Join("/data", user, "dc", datacenterName)
+}
+
+func datacenterDirectory(user, datacenterName string) string {
+	return filepath.Join("public", "data", user, "dc", datacenterName)
+}
+
+func listUsers() []string {
+	users := []string{}
+	files, _ := ioutil.ReadDir(filepath.Join("public", "data"))
+	for _, file := range files {
+		users = append(users, file.Name())
+	}
+	return users
+}
+func listVersions(folderPath string) ([]int, error) {
+	versions := []int{}
+	files, err := ioutil.ReadDir(folderPath)
+	if err != nil {
+		return nil, err
+	}
+	max := 0
+	for _, file := range files {
+		if file.Name()[0] == 'v' && len(file.Name()) > 1 {
+			i, err := strconv.Atoi(file.Name()[1:])
+			if err == nil && i > max {
+				versions = append(versions, i)
+			}
+		}
+	}
+	return versions, nil
+}
+func latestVersionInt(folderPath string) (int, error) {
+	max := 0
+	if l, err := listVersions(folderPath); err == nil {
+		for _, i := range l {
+			if err == nil && i > max {
+				max = i
+			}
+		}
+	}
+	if max == 0 {
+		return 0, fmt.Errorf("No version found in folderPath")
+	}
+	return max, nil
+}
+
+func nextVersion(folderPath string) (string, error) {
+	v, _ := latestVersionInt(folderPath)
+	v++
+	return fmt.Sprintf("v%d", v), nil
+}
+func latestVersion(folderPath string) (string, error) {
+	v, _ := latestVersionInt(folderPath)
+	return fmt.Sprintf("v%d", v), nil
+}
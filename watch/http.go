@@ -0,0 +1,117 @@
+package watch
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// Handler serves the page of the latest build, its svg graph at /graph.svg and the server-sent events telling the page
+// to reload at /events.
+func (w *Watcher) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", w.page)
+	mux.HandleFunc("/graph.svg", w.graph)
+	mux.HandleFunc("/events", w.events)
+	return mux
+}
+
+func (w *Watcher) page(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(rw, r)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pageTemplate.Execute(rw, w.Current()); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (w *Watcher) graph(rw http.ResponseWriter, r *http.Request) {
+	b := w.Current()
+	switch {
+	case b.Report == nil:
+		http.Error(rw, "the blueprint does not load, see the problems on /", http.StatusServiceUnavailable)
+	case b.Report.SVG == "":
+		http.Error(rw, "the graphviz dot command is not available", http.StatusNotFound)
+	default:
+		rw.Header().Set("Content-Type", "image/svg+xml")
+		fmt.Fprint(rw, b.Report.SVG)
+	}
+}
+
+// events streams the generation of each build, the page reloads when it differs from the one it shows.
+func (w *Watcher) events(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	c := w.subscribe()
+	defer w.unsubscribe(c)
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(rw, "data: %d\n\n", w.Current().Generation)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case g := <-c:
+			fmt.Fprintf(rw, "data: %d\n\n", g)
+			flusher.Flush()
+		}
+	}
+}
+
+var pageTemplate = template.Must(template.New("watch").Funcs(template.FuncMap{
+	"svg":  func(s string) template.HTML { return template.HTML(s) },
+	"time": func(b Build) string { return b.Time.Format("15:04:05") },
+	"trim": strings.TrimSpace,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{if .Report}}{{.Report.Title}}{{else}}blueprint{{end}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; font-size: 90%; vertical-align: top; }
+th { background: #eee; }
+pre { margin: 0; }
+.error { color: #a00; }
+.warning { color: #a60; }
+.status { color: #666; }
+</style>
+</head>
+<body>
+<p class="status">Build {{.Generation}} at {{time .}}, {{.Errors}} error(s). The page reloads when the blueprint changes.</p>
+{{if .Problems}}<h2>Problems</h2>
+<table>
+<tr><th>Severity</th><th>Location</th><th>Problem</th><th>Context</th></tr>
+{{range .Problems}}<tr class="{{if .Warning}}warning{{else}}error{{end}}"><td>{{if .Warning}}warning{{else}}error{{end}}</td><td>{{.Location}}</td><td>{{.Message}}</td><td><pre>{{trim .Context}}</pre></td></tr>
+{{end}}</table>{{end}}
+{{with .Report}}
+{{if .Violations}}<h2>Policy violations</h2>
+<table>
+<tr><th>Policy</th><th>Bucket</th><th>Violation</th></tr>
+{{range .Violations}}<tr class="error"><td>{{.Policy}}</td><td>{{.Bucket}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Conflicts}}<h2>Conflicts</h2>
+<ul>
+{{range .Conflicts}}<li class="warning">{{.String}}</li>
+{{end}}</ul>{{end}}
+<h2>Graph</h2>
+{{if .SVG}}{{svg .SVG}}{{else}}<p class="warning">The graph is not rendered, graphviz is not installed. Its mermaid source follows.</p>
+<pre>{{.Mermaid}}</pre>{{end}}
+{{end}}
+<script>
+new EventSource("/events").onmessage = function(e) {
+  if (parseInt(e.data, 10) !== {{.Generation}}) { location.reload(); }
+};
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,205 @@
+// Package watch rebuilds a blueprint when its files change and serves its latest graph, reloaded live in the
+// browser.
+package watch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dbenque/couchbaseblueprint/expansion"
+	"github.com/dbenque/couchbaseblueprint/report"
+)
+
+// Build is the result of loading the blueprint after a change.
+type Build struct {
+	Generation int
+	Time       time.Time
+	// Report is the report of the blueprint, nil when it could not be loaded
+	Report *report.Report
+	// Problems are the problems preventing the blueprint from loading, or the ones found by its validation
+	Problems []expansion.Problem
+}
+
+// Errors returns the number of problems of the build that are not warnings, the policy violations included.
+func (b Build) Errors() int {
+	count := 0
+	for _, p := range b.Problems {
+		if !p.Warning {
+			count++
+		}
+	}
+	if b.Report != nil {
+		count += len(b.Report.Violations)
+	}
+	return count
+}
+
+// stamp identifies a version of a file, the zero stamp standing for a missing file.
+type stamp struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// Watcher loads the blueprint again each time one of its files changes.
+type Watcher struct {
+	title      string
+	load       func() (*expansion.Blueprint, error)
+	policyFile string
+	// inputs are the paths given on the command line, the entries of the folders are watched as well
+	inputs []string
+
+	mu      sync.Mutex
+	build   Build
+	files   []string
+	stamps  map[string]stamp
+	clients map[chan int]bool
+}
+
+// New returns a watcher of the blueprint read by load from the inputs, checked against the optional policy file.
+func New(title string, load func() (*expansion.Blueprint, error), policyFile string, inputs ...string) *Watcher {
+	return &Watcher{title: title, load: load, policyFile: policyFile, inputs: inputs, clients: map[chan int]bool{}}
+}
+
+// Current returns the latest build.
+func (w *Watcher) Current() Build {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.build
+}
+
+// Rebuild loads the blueprint, builds its report and notifies the browsers.
+func (w *Watcher) Rebuild() Build {
+	b := Build{Time: time.Now()}
+	files := []string{}
+	bp, err := w.load()
+	if err == nil {
+		files = append(files, bp.Files...)
+		var policies []expansion.Policy
+		if w.policyFile != "" {
+			policies, err = expansion.PoliciesFromFile(w.policyFile)
+		}
+		if err == nil {
+			b.Report, err = report.New(w.title, bp, policies)
+		}
+	}
+	if err != nil {
+		b.Report = nil
+		if fe, ok := err.(*expansion.FileError); ok {
+			b.Problems = fe.Problems
+		} else {
+			b.Problems = []expansion.Problem{{Index: -1, Message: err.Error()}}
+		}
+	} else {
+		b.Problems = b.Report.Problems
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// the files of the last successful build are kept watched while the blueprint is broken
+	if bp != nil || w.files == nil {
+		w.files = files
+	}
+	w.stamps = w.snapshot()
+	b.Generation = w.build.Generation + 1
+	w.build = b
+	for c := range w.clients {
+		select {
+		case c <- b.Generation:
+		default:
+		}
+	}
+	return b
+}
+
+// watched returns the files whose changes trigger a rebuild. The caller holds the lock.
+func (w *Watcher) watched() []string {
+	set := map[string]bool{}
+	for _, f := range w.files {
+		set[f] = true
+	}
+	if w.policyFile != "" {
+		set[w.policyFile] = true
+	}
+	for _, in := range w.inputs {
+		set[in] = true
+		if entries, err := ioutil.ReadDir(in); err == nil {
+			for _, e := range entries {
+				if !e.IsDir() {
+					set[filepath.Join(in, e.Name())] = true
+				}
+			}
+		}
+	}
+	files := []string{}
+	for f := range set {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// snapshot returns the stamps of the watched files. The caller holds the lock.
+func (w *Watcher) snapshot() map[string]stamp {
+	stamps := map[string]stamp{}
+	for _, f := range w.watched() {
+		if fi, err := os.Stat(f); err == nil {
+			stamps[f] = stamp{ModTime: fi.ModTime(), Size: fi.Size()}
+		} else {
+			stamps[f] = stamp{}
+		}
+	}
+	return stamps
+}
+
+// Changed tells whether a watched file was modified, created or removed since the latest build.
+func (w *Watcher) Changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	current := w.snapshot()
+	if len(current) != len(w.stamps) {
+		return true
+	}
+	for f, s := range current {
+		if previous, ok := w.stamps[f]; !ok || !previous.ModTime.Equal(s.ModTime) || previous.Size != s.Size {
+			return true
+		}
+	}
+	return false
+}
+
+// Run builds the blueprint, then polls its files every interval and rebuilds it on change until stop is closed.
+// onBuild is called after each build.
+func (w *Watcher) Run(interval time.Duration, onBuild func(Build), stop <-chan struct{}) {
+	onBuild(w.Rebuild())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if w.Changed() {
+				onBuild(w.Rebuild())
+			}
+		}
+	}
+}
+
+// subscribe returns a channel receiving the generation of each new build.
+func (w *Watcher) subscribe() chan int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	c := make(chan int, 1)
+	w.clients[c] = true
+	return c
+}
+
+func (w *Watcher) unsubscribe(c chan int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.clients, c)
+}